@@ -2,9 +2,15 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"kv-engine/internal/cli"
@@ -25,6 +31,20 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Ctrl+C / kill: uredno ugasi engine pa izadji
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sigs
+		fmt.Println()
+		closeEngine(eng)
+		os.Exit(0)
+	}()
+
+	if n := eng.RecoveredRecords(); n > 0 {
+		fmt.Printf("recovered %d records from WAL\n", n)
+	}
+
 	fmt.Print(`KV engine ready.
 Formats:
   PUT(key,value)
@@ -32,9 +52,32 @@ Formats:
   PUT(key,value,10s)   // TTL optional: 10s / 5m / 2h
   GET(key)
   DELETE(key)
+  RANGE_SCAN(start,end,page,pageSize)  // keys in [start, end]; pages start at 1
+  PREFIX_SCAN(prefix,page,pageSize)
+  BATCH_BEGIN          // PUT/DELETE are queued until BATCH_COMMIT (or BATCH_ABORT)
+  BATCH_COMMIT
+  BATCH_ABORT
+  TXN_BEGIN            // GET reads the state as of TXN_BEGIN; PUT/DELETE are queued until TXN_COMMIT (or TXN_ABORT)
+  TXN_COMMIT           // fails if a key read by the transaction has changed since
+  TXN_ABORT
+  VERIFY               // checks SSTables against their merkle trees
   EXIT
 `)
 
+	// otvoren batch ili transakcija: PUT i DELETE idu u njih umesto direktno
+	// u engine
+	var batch *engine.WriteBatch
+	var txn *engine.Txn
+	pending := func() queue {
+		if batch != nil {
+			return batch
+		}
+		if txn != nil {
+			return txn
+		}
+		return nil
+	}
+
 	sc := bufio.NewScanner(os.Stdin)
 	for {
 		fmt.Print("> ")
@@ -56,6 +99,7 @@ Formats:
 
 		switch cmd {
 		case "EXIT", "QUIT":
+			closeEngine(eng)
 			return
 
 		case "GET":
@@ -63,7 +107,11 @@ Formats:
 				fmt.Println("usage: GET(key)")
 				continue
 			}
-			val, found, err := eng.Get(args[0])
+			get := eng.Get
+			if txn != nil {
+				get = txn.Get
+			}
+			val, found, err := get(args[0])
 			if err != nil {
 				fmt.Println("error:", err)
 				continue
@@ -79,6 +127,11 @@ Formats:
 				fmt.Println("usage: DELETE(key)")
 				continue
 			}
+			if q := pending(); q != nil {
+				q.Delete(args[0])
+				fmt.Println("QUEUED")
+				continue
+			}
 			if err := eng.Delete(args[0]); err != nil {
 				fmt.Println("error:", err)
 				continue
@@ -98,6 +151,11 @@ Formats:
 
 			// no ttl
 			if len(args) == 2 {
+				if q := pending(); q != nil {
+					q.Put(key, value)
+					fmt.Println("QUEUED")
+					continue
+				}
 				if err := eng.Put(key, value); err != nil {
 					fmt.Println("error:", err)
 					continue
@@ -112,6 +170,11 @@ Formats:
 				fmt.Println("invalid TTL, use 10s, 5m, 2h")
 				continue
 			}
+			if q := pending(); q != nil {
+				q.Put(key, value, dur)
+				fmt.Println("QUEUED")
+				continue
+			}
 
 			if err := eng.Put(key, value, dur); err != nil {
 				fmt.Println("error:", err)
@@ -119,6 +182,134 @@ Formats:
 			}
 			fmt.Println("OK")
 
+		case "RANGE_SCAN":
+			if len(args) != 4 {
+				fmt.Println("usage: RANGE_SCAN(start,end,page,pageSize)")
+				continue
+			}
+			page, size, ok := parsePage(args[2], args[3])
+			if !ok {
+				continue
+			}
+			kvs, err := eng.Scan(args[0], args[1], page*size)
+			if err != nil {
+				fmt.Println("error:", err)
+				continue
+			}
+			printPage(kvs, page, size)
+
+		case "PREFIX_SCAN":
+			if len(args) != 3 {
+				fmt.Println("usage: PREFIX_SCAN(prefix,page,pageSize)")
+				continue
+			}
+			page, size, ok := parsePage(args[1], args[2])
+			if !ok {
+				continue
+			}
+			kvs, err := eng.PrefixScan(args[0], page*size)
+			if err != nil {
+				fmt.Println("error:", err)
+				continue
+			}
+			printPage(kvs, page, size)
+
+		case "BATCH_BEGIN":
+			if batch != nil {
+				fmt.Println("batch already open")
+				continue
+			}
+			if txn != nil {
+				fmt.Println("transaction open, commit or abort it first")
+				continue
+			}
+			batch = &engine.WriteBatch{}
+			fmt.Println("OK")
+
+		case "BATCH_COMMIT":
+			if batch == nil {
+				fmt.Println("no open batch")
+				continue
+			}
+			n := batch.Len()
+			err := eng.Write(batch)
+			batch = nil
+			if err != nil {
+				fmt.Println("error:", err)
+				continue
+			}
+			fmt.Printf("OK (%d operations)\n", n)
+
+		case "BATCH_ABORT":
+			if batch == nil {
+				fmt.Println("no open batch")
+				continue
+			}
+			batch = nil
+			fmt.Println("OK")
+
+		case "TXN_BEGIN":
+			if txn != nil {
+				fmt.Println("transaction already open")
+				continue
+			}
+			if batch != nil {
+				fmt.Println("batch open, commit or abort it first")
+				continue
+			}
+			t, err := eng.BeginTxn()
+			if err != nil {
+				fmt.Println("error:", err)
+				continue
+			}
+			txn = t
+			fmt.Println("OK")
+
+		case "TXN_COMMIT":
+			if txn == nil {
+				fmt.Println("no open transaction")
+				continue
+			}
+			err := txn.Commit()
+			txn = nil
+			if errors.Is(err, engine.ErrConflict) {
+				fmt.Println("CONFLICT (transaction aborted)")
+				continue
+			}
+			if err != nil {
+				fmt.Println("error:", err)
+				continue
+			}
+			fmt.Println("OK")
+
+		case "TXN_ABORT":
+			if txn == nil {
+				fmt.Println("no open transaction")
+				continue
+			}
+			txn.Rollback()
+			txn = nil
+			fmt.Println("OK")
+
+		case "VERIFY":
+			results, err := eng.VerifySSTables()
+			if err != nil {
+				fmt.Println("error:", err)
+				continue
+			}
+			corrupted := 0
+			for _, r := range results {
+				switch {
+				case r.Err != nil:
+					fmt.Printf("%s: cannot verify: %v\n", r.Table, r.Err)
+					corrupted++
+				case len(r.CorruptedBlocks) > 0:
+					fmt.Printf("%s: corrupted blocks %v\n", r.Table, r.CorruptedBlocks)
+					corrupted++
+				}
+			}
+			fmt.Printf("verified %d tables, %d with problems\n", len(results), corrupted)
+
 		default:
 			fmt.Println("unknown command")
 		}
@@ -127,4 +318,50 @@ Formats:
 	if err := sc.Err(); err != nil {
 		fmt.Println("input error:", err)
 	}
+	closeEngine(eng)
+}
+
+// parsePage cita broj strane (od 1) i velicinu strane.
+func parsePage(pageArg, sizeArg string) (int, int, bool) {
+	page, err1 := strconv.Atoi(pageArg)
+	size, err2 := strconv.Atoi(sizeArg)
+	if err1 != nil || err2 != nil || page < 1 || size < 1 {
+		fmt.Println("page and pageSize must be positive integers")
+		return 0, 0, false
+	}
+	return page, size, true
+}
+
+// printPage ispisuje poslednju stranu iz kvs; scan je vec ogranicen na
+// page*size kljuceva.
+func printPage(kvs []engine.KV, page, size int) {
+	from := (page - 1) * size
+	if from >= len(kvs) {
+		fmt.Println("(empty page)")
+		return
+	}
+	for _, kv := range kvs[from:] {
+		fmt.Printf("%s = %s\n", kv.Key, kv.Value)
+	}
+	fmt.Printf("page %d: %d keys\n", page, len(kvs)-from)
+}
+
+// queue prima PUT i DELETE dok je otvoren batch ili transakcija.
+type queue interface {
+	Put(key string, value []byte, ttl ...time.Duration)
+	Delete(key string)
+}
+
+var closeOnce sync.Once
+
+// closeEngine moze da se pozove i iz signal handler-a i iz petlje; drugi
+// poziv ceka da se prvo gasenje zavrsi.
+func closeEngine(eng *engine.Engine) {
+	closeOnce.Do(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := eng.Close(ctx); err != nil {
+			fmt.Println("close error:", err)
+		}
+	})
 }
@@ -21,3 +21,7 @@ func (c *BlockCache) Get(key BlockKey) ([]byte, bool) {
 func (c *BlockCache) Put(key BlockKey, data []byte) {
 	c.lru.Put(key, data)
 }
+
+func (c *BlockCache) Rename(oldPath, newPath string) {
+	c.lru.Rename(oldPath, newPath)
+}
@@ -2,10 +2,13 @@ package block
 
 import (
 	"container/list"
+	"sync"
 )
 
-// LRUList je generička pomoćna lista za LRU cache
+// LRUList je generička pomoćna lista za LRU cache.
+// I Get menja listu (MoveToFront), pa sve operacije idu pod istim lock-om.
 type LRUList struct {
+	mu          sync.Mutex
 	ll          *list.List
 	table       map[BlockKey]*list.Element
 	cacheSize   int
@@ -29,6 +32,9 @@ func NewLRUList(size int) *LRUList {
 
 // Get vraća vrednost i pomera entry na vrh (most recently used)
 func (l *LRUList) Get(key BlockKey) ([]byte, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
 	if elem, ok := l.table[key]; ok {
 		l.ll.MoveToFront(elem)
 		return elem.Value.(*lruEntry).value, true
@@ -38,6 +44,9 @@ func (l *LRUList) Get(key BlockKey) ([]byte, bool) {
 
 // Put dodaje ili osvežava vrednost i premesta je na vrh
 func (l *LRUList) Put(key BlockKey, value []byte) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
 	// Ako entry već postoji, osveži ga i pomeri na vrh
 	if elem, ok := l.table[key]; ok {
 		oldSize := len(elem.Value.(*lruEntry).value)
@@ -70,3 +79,25 @@ func (l *LRUList) removeOldest() {
 		l.currentSize -= len(entry.value)
 	}
 }
+
+// Rename prebacuje sve entry-je fajla oldPath na newPath, bez promene
+// redosleda u listi.
+func (l *LRUList) Rename(oldPath, newPath string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for key, elem := range l.table {
+		if key.Path != oldPath {
+			continue
+		}
+		entry := elem.Value.(*lruEntry)
+		delete(l.table, key)
+		entry.key.Path = newPath
+		if old, ok := l.table[entry.key]; ok {
+			// zastareo blok fajla koji je bio na newPath
+			l.ll.Remove(old)
+			l.currentSize -= len(old.Value.(*lruEntry).value)
+		}
+		l.table[entry.key] = elem
+	}
+}
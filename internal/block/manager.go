@@ -152,3 +152,53 @@ func (bm *BlockManager) AppendBlock(path string, data []byte, blockSize int) (ui
 
 	return blockNum, nil
 }
+
+// BlockWriter upisuje blokove redom u jedan otvoren fajl, mimo cache-a:
+// fajl koji se tek pise niko ne cita, a punjenje cache-a pri upisu bi
+// izbacilo blokove koje cita ostatak sistema.
+type BlockWriter struct {
+	file      *os.File
+	blockSize int
+	next      uint64 // broj sledeceg bloka
+}
+
+// Create pravi prazan fajl (postojeci se brise) za upis blokova.
+func (bm *BlockManager) Create(path string, blockSize int) (*BlockWriter, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &BlockWriter{file: file, blockSize: blockSize}, nil
+}
+
+// Append upisuje blok na kraj fajla i vraca njegov broj. data se ne cuva,
+// pa pozivalac sme ponovo da ga koristi.
+func (w *BlockWriter) Append(data []byte) (uint64, error) {
+	if len(data) != w.blockSize {
+		return 0, ErrInvalidBlockSize
+	}
+	if _, err := w.file.Write(data); err != nil {
+		return 0, err
+	}
+	w.next++
+	return w.next - 1, nil
+}
+
+// Sync radi fsync fajla.
+func (w *BlockWriter) Sync() error {
+	return w.file.Sync()
+}
+
+func (w *BlockWriter) Close() error {
+	return w.file.Close()
+}
+
+// Rename preimenuje fajl na disku; blokovi koji su vec u cache-u ostaju u
+// njemu pod novom putanjom.
+func (bm *BlockManager) Rename(oldPath, newPath string) error {
+	if err := os.Rename(oldPath, newPath); err != nil {
+		return err
+	}
+	bm.cache.Rename(oldPath, newPath)
+	return nil
+}
@@ -9,6 +9,9 @@ import "strings"
 //	PUT(key,value,10s)
 //	GET(key)
 //	DELETE(key)
+//	RANGE_SCAN(a,z,1,10)
+//	VERIFY
+//	BATCH_BEGIN
 //
 // returns: cmd, args, ok, errMsg
 func ParseCall(line string) (string, []string, bool, string) {
@@ -17,9 +20,11 @@ func ParseCall(line string) (string, []string, bool, string) {
 		return "", nil, false, ""
 	}
 
-	// allow EXIT/QUIT without parentheses
+	// allow commands without arguments without parentheses
 	up := strings.ToUpper(line)
-	if up == "EXIT" || up == "QUIT" {
+	switch up {
+	case "EXIT", "QUIT", "VERIFY", "BATCH_BEGIN", "BATCH_COMMIT", "BATCH_ABORT",
+		"TXN_BEGIN", "TXN_COMMIT", "TXN_ABORT":
 		return up, nil, true, ""
 	}
 
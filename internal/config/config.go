@@ -7,30 +7,52 @@ import (
 )
 
 type Config struct {
-	DataDir              string `json:"data_dir"`
-	BlockSize            int    `json:"block_size"`
-	MemtableMaxEntries   int    `json:"memtable_max_entries"`
-	WALSegmentMaxRecords int    `json:"wal_segment_max_records"`
-	MultiFileSSTable     bool   `json:"multi_file_sstable"`
-	MemtableMaxBytes     int64  `json:"memtable_max_bytes"`
-	MemtableType         string `json:"memtable_type"`
-	BTreeDegree          int    `json:"btree_degree"`
-	MemtableInstances    int    `json:"memtable_instances"`
-	CacheSize            int    `json:"cache_size"`
+	DataDir                string  `json:"data_dir"`
+	BlockSize              int     `json:"block_size"`
+	MemtableMaxEntries     int     `json:"memtable_max_entries"`
+	WALSegmentMaxRecords   int     `json:"wal_segment_max_records"`
+	MultiFileSSTable       bool    `json:"multi_file_sstable"`
+	MemtableMaxBytes       int64   `json:"memtable_max_bytes"`
+	MemtableType           string  `json:"memtable_type"`
+	BTreeDegree            int     `json:"btree_degree"`
+	MemtableInstances      int     `json:"memtable_instances"`
+	CacheSize              int     `json:"cache_size"`
+	WALSyncMode            string  `json:"wal_sync_mode"`
+	WALSyncIntervalMs      int     `json:"wal_sync_interval_ms"`
+	FlushOnClose           bool    `json:"flush_on_close"`
+	SummaryStep            int     `json:"summary_step"`
+	BloomFalsePositiveRate float64 `json:"bloom_false_positive_rate"`
+	CompactionStrategy     string  `json:"compaction_strategy"`
+	L0CompactionTrigger    int     `json:"l0_compaction_trigger"`
+	LevelBaseMaxBytes      int64   `json:"level_base_max_bytes"`
+	LevelSizeMultiplier    int     `json:"level_size_multiplier"`
+	MaxLevels              int     `json:"max_levels"`
+	SSTableTargetFileBytes int64   `json:"sstable_target_file_bytes"`
 }
 
 func Default() Config {
 	return Config{
-		DataDir:              "data",
-		BlockSize:            4096,
-		MemtableMaxEntries:   1000,
-		WALSegmentMaxRecords: 1000,
-		MultiFileSSTable:     true,
-		MemtableMaxBytes:     1024,
-		MemtableType:         "hashmap",
-		BTreeDegree:          16,
-		MemtableInstances:    1,
-		CacheSize:            8192,
+		DataDir:                "data",
+		BlockSize:              4096,
+		MemtableMaxEntries:     1000,
+		WALSegmentMaxRecords:   1000,
+		MultiFileSSTable:       true,
+		MemtableMaxBytes:       1024,
+		MemtableType:           "hashmap",
+		BTreeDegree:            16,
+		MemtableInstances:      1,
+		CacheSize:              8192,
+		WALSyncMode:            "always",
+		WALSyncIntervalMs:      100,
+		FlushOnClose:           true,
+		SummaryStep:            16,
+		BloomFalsePositiveRate: 0.01,
+		CompactionStrategy:     "leveled",
+		L0CompactionTrigger:    4,
+		LevelBaseMaxBytes:      1 << 20,
+		LevelSizeMultiplier:    10,
+		MaxLevels:              4,
+		SSTableTargetFileBytes: 256 << 10,
 	}
 }
 
@@ -87,6 +109,54 @@ func (c *Config) Normalize() {
 	if c.CacheSize <= 0 {
 		c.CacheSize = d.CacheSize
 	}
+
+	// WALSyncMode: always / interval / none
+	switch c.WALSyncMode {
+	case "always", "interval", "none":
+		// ok
+	default:
+		c.WALSyncMode = d.WALSyncMode
+	}
+	if c.WALSyncIntervalMs <= 0 {
+		c.WALSyncIntervalMs = d.WALSyncIntervalMs
+	}
+
+	// SummaryStep: svaki N-ti kljuc iz index-a ide u summary, N >= 1
+	if c.SummaryStep < 1 {
+		c.SummaryStep = d.SummaryStep
+	}
+
+	// BloomFalsePositiveRate mora biti u (0, 1)
+	if c.BloomFalsePositiveRate <= 0 || c.BloomFalsePositiveRate >= 1 {
+		c.BloomFalsePositiveRate = d.BloomFalsePositiveRate
+	}
+
+	// Kompakcija: leveled / size_tiered.
+	// Leveled: L0 se spaja u L1 kada ima L0CompactionTrigger tabela, a nivo
+	// i >= 1 kada predje LevelBaseMaxBytes * LevelSizeMultiplier^(i-1).
+	// MaxLevels broji i L0, pa mora biti >= 2.
+	// Size-tiered: L0CompactionTrigger slicnih tabela se spaja u jednu.
+	switch c.CompactionStrategy {
+	case "leveled", "size_tiered":
+		// ok
+	default:
+		c.CompactionStrategy = d.CompactionStrategy
+	}
+	if c.L0CompactionTrigger < 2 {
+		c.L0CompactionTrigger = d.L0CompactionTrigger
+	}
+	if c.LevelBaseMaxBytes <= 0 {
+		c.LevelBaseMaxBytes = d.LevelBaseMaxBytes
+	}
+	if c.LevelSizeMultiplier < 2 {
+		c.LevelSizeMultiplier = d.LevelSizeMultiplier
+	}
+	if c.MaxLevels < 2 {
+		c.MaxLevels = d.MaxLevels
+	}
+	if c.SSTableTargetFileBytes <= 0 {
+		c.SSTableTargetFileBytes = d.SSTableTargetFileBytes
+	}
 }
 
 func Load(path string) (Config, error) {
@@ -0,0 +1,54 @@
+package engine
+
+import (
+	"time"
+
+	"kv-engine/internal/model"
+)
+
+// WriteBatch skuplja Put i Delete operacije koje se upisuju zajedno sa
+// Engine.Write. Nulta vrednost je prazan batch spreman za upotrebu. Nije
+// bezbedan za konkurentno koriscenje.
+type WriteBatch struct {
+	recs []model.Record
+}
+
+// Put dodaje upis kljuca; ttl je opcioni kao kod Engine.Put i racuna se od
+// trenutka dodavanja.
+func (b *WriteBatch) Put(key string, value []byte, ttl ...time.Duration) {
+	var expiresAt uint64
+	if len(ttl) > 0 {
+		expiresAt = uint64(time.Now().Add(ttl[0]).Unix())
+	}
+	b.recs = append(b.recs, model.Record{Key: key, Value: value, ExpiresAt: expiresAt})
+}
+
+// Delete dodaje brisanje kljuca.
+func (b *WriteBatch) Delete(key string) {
+	b.recs = append(b.recs, model.Record{Key: key, Tombstone: true})
+}
+
+// Len vraca broj operacija u batch-u.
+func (b *WriteBatch) Len() int {
+	return len(b.recs)
+}
+
+// Reset prazni batch da bi mogao ponovo da se koristi.
+func (b *WriteBatch) Reset() {
+	b.recs = b.recs[:0]
+}
+
+// Write atomski upisuje sve operacije iz batch-a: u WAL idu kao jedan zapis
+// sa uzastopnim seq-ovima, pa posle pada ili vrate sve ili nijedna, a u
+// memtable se primenjuju odjednom, pa ih citanja vide sve ili nijednu. Ako se
+// isti kljuc javlja vise puta, vazi poslednja operacija. Batch posle Write-a
+// ostaje nepromenjen.
+func (e *Engine) Write(b *WriteBatch) error {
+	if e.closed.Load() {
+		return ErrClosed
+	}
+	if b.Len() == 0 {
+		return nil
+	}
+	return e.writeBatch(append([]model.Record(nil), b.recs...))
+}
@@ -0,0 +1,98 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrClosed vracaju sve operacije nad engine-om posle Close-a.
+var ErrClosed = errors.New("engine: closed")
+
+// Close gasi engine: ceka upise koji su vec u toku, zaustavlja pozadinski
+// flush i kompakciju, (ako je flush_on_close ukljucen) flush-uje sve memtable
+// tabele, pa radi fsync i zatvara manifest i WAL i na kraju oslobadja lock nad DataDir-om.
+// Ako ctx istekne pre kraja flush-a, preostali podaci ostaju samo u WAL-u i
+// vratice se replay-om pri sledecem startu. Prekinuta kompakcija se ponavlja
+// pri sledecem startu.
+//
+// Ako ctx istekne dok flusher ili kompakcija jos rade, Close vraca gresku
+// odmah, ali manifest, WAL i lock ostaju dok se obe gorutine ne zavrse, jer
+// do tada jos pisu u DataDir; zatvaraju se u pozadini.
+func (e *Engine) Close(ctx context.Context) error {
+	e.writeMu.Lock()
+	if e.closed.Load() {
+		e.writeMu.Unlock()
+		return ErrClosed
+	}
+	e.closed.Store(true)
+	for len(e.writers) > 0 {
+		e.writeCond.Wait()
+	}
+	e.writeMu.Unlock()
+
+	// 1) zaustavi flusher (zavrsava tabelu koju trenutno pise) i prekini
+	// kompakciju (do tada upisane tabele se brisu, manifest ostaje star)
+	close(e.flushCh)
+	close(e.compactStop)
+	stopped := make(chan struct{})
+	go func() {
+		<-e.flushDone
+		<-e.compactDone
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		go func() {
+			<-stopped
+			e.release()
+		}()
+		return fmt.Errorf("engine: close: %w; DataDir stays locked until background flush and compaction stop", ctx.Err())
+	}
+
+	var errs []error
+	e.compactMu.Lock()
+	errs = append(errs, e.compactErr)
+	e.compactMu.Unlock()
+
+	// 2) flush svih memtable tabela
+	if e.cfg.FlushOnClose {
+		errs = append(errs, e.flushAll(ctx))
+	}
+
+	// 3) manifest, WAL i lock
+	errs = append(errs, e.release())
+	return errors.Join(errs...)
+}
+
+// release zatvara manifest i WAL i na kraju oslobadja lock nad DataDir-om.
+// Sme da se pozove tek kada flusher i kompakcija vise ne rade.
+func (e *Engine) release() error {
+	var errs []error
+	errs = append(errs, e.sst.Close())
+	errs = append(errs, storeSeq(e.cfg.DataDir, e.seq.Load()))
+	errs = append(errs, e.wal.Close())
+
+	// lock tek na kraju, kada vise nista ne pise u DataDir
+	errs = append(errs, e.lock.Unlock())
+	return errors.Join(errs...)
+}
+
+func (e *Engine) flushAll(ctx context.Context) error {
+	if err := e.mem.FreezeActive(); err != nil {
+		return err
+	}
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		flushed, err := e.flushMemtable()
+		if err != nil {
+			return err
+		}
+		if !flushed {
+			return nil
+		}
+	}
+}
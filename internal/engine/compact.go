@@ -0,0 +1,59 @@
+package engine
+
+import "time"
+
+// Posle neuspele kompakcije sledeci pokusaj ceka, sve duze do
+// compactRetryMax, da greska koja se ponavlja ne bi trosila I/O na svaki
+// flush.
+const (
+	compactRetryMin = 100 * time.Millisecond
+	compactRetryMax = 30 * time.Second
+)
+
+// triggerCompaction budi pozadinsku kompakciju. compactCh se nikad ne
+// zatvara, pa je bezbedna i dok Close vec gasi engine (flusher koji jos radi).
+func (e *Engine) triggerCompaction() {
+	if e.closed.Load() {
+		return
+	}
+	select {
+	case e.compactCh <- struct{}{}:
+	default:
+	}
+}
+
+// compactLoop posle svakog flush-a proverava da li neki nivo treba kompaktovati.
+// Zavrsava se kada Close zatvori compactStop, koji prekida i kompakciju koja
+// je u toku.
+func (e *Engine) compactLoop() {
+	defer close(e.compactDone)
+
+	var backoff time.Duration
+	for {
+		select {
+		case <-e.compactStop:
+			return
+		case <-e.compactCh:
+		}
+		err := e.sst.Compact(e.compactStop, e.snapshotList)
+
+		e.compactMu.Lock()
+		e.compactErr = err
+		e.compactMu.Unlock()
+
+		if err == nil {
+			backoff = 0
+			continue
+		}
+		// flush-evi tokom cekanja ostavljaju jedan signal, pa posle cekanja
+		// ide tacno jedan novi pokusaj
+		backoff = min(max(2*backoff, compactRetryMin), compactRetryMax)
+		t := time.NewTimer(backoff)
+		select {
+		case <-e.compactStop:
+			t.Stop()
+			return
+		case <-t.C:
+		}
+	}
+}
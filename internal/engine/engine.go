@@ -1,12 +1,17 @@
 package engine
 
 import (
+	"fmt"
+	"math"
 	"os"
 	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"kv-engine/internal/block"
 	"kv-engine/internal/config"
+	"kv-engine/internal/fileutil"
 	"kv-engine/internal/memtable"
 	"kv-engine/internal/model"
 	"kv-engine/internal/sstable"
@@ -19,14 +24,74 @@ type Engine struct {
 	wal *wal.WAL
 	mem memtable.MemtableManagerIface
 	sst *sstable.Manager
-	seq uint64
+	seq atomic.Uint64 // povecava ga samo lider write pipeline-a (ili replay pri startu)
+
+	snaps snapshots // otvoreni snapshot-i i seq koji vide citanja
+
+	// write pipeline: red upisa koji cekaju, prvi u redu je lider
+	writeMu   sync.Mutex
+	writeCond *sync.Cond
+	writers   []*writeReq
+	closed    atomic.Bool // postavlja se pod writeMu, cita se i bez njega
+	failed    error       // upis je trajan u WAL-u, ali nije primenjen; pod writeMu
+
+	// pozadinski flush: flushCh budi flusher, flushCond budi upise koji cekaju slot
+	flushCh   chan struct{}
+	flushDone chan struct{}
+	flushMu   sync.Mutex
+	flushCond *sync.Cond
+	flushErr  error // poslednja greska pozadinskog flush-a
+	stalls    atomic.Uint64
+
+	// pozadinska kompakcija SSTable-ova; budi je svaki flush
+	compactCh   chan struct{}
+	compactDone chan struct{}
+	compactStop chan struct{}
+	compactMu   sync.Mutex
+	compactErr  error // greska poslednje kompakcije
+
+	recovered int // broj zapisa vracenih iz WAL-a pri startu
+
+	lock *fileutil.FileLock // lock nad DataDir-om, drzi se do Close-a
 }
 
+// Stats su brojaci rada engine-a.
+type Stats struct {
+	WriteStalls uint64 // koliko puta je upis cekao jer su sve memtable tabele cekale flush
+
+	Filter     sstable.FilterStats     // bloom filteri SSTable-ova
+	Compaction sstable.CompactionStats // kompakcija SSTable-ova
+	// CompactionErr je greska poslednje kompakcije; nil posle uspesne
+	CompactionErr error
+}
+
+// lockFile u DataDir-u sprecava da dva procesa istovremeno otvore isti store.
+const lockFile = "LOCK"
+
 func New(cfg config.Config) (*Engine, error) {
 	if err := os.MkdirAll(cfg.DataDir, 0755); err != nil {
 		return nil, err
 	}
 
+	lock, err := fileutil.Lock(filepath.Join(cfg.DataDir, lockFile))
+	if err == fileutil.ErrLocked {
+		return nil, fmt.Errorf("data dir %q is already in use by another process", cfg.DataDir)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("lock data dir %q: %w", cfg.DataDir, err)
+	}
+
+	e, err := open(cfg)
+	if err != nil {
+		lock.Unlock()
+		return nil, err
+	}
+	e.lock = lock
+	return e, nil
+}
+
+func open(cfg config.Config) (*Engine, error) {
+
 	fact, err := memtable.FactoryFromConfig(cfg)
 	if err != nil {
 		return nil, err
@@ -37,97 +102,186 @@ func New(cfg config.Config) (*Engine, error) {
 		return nil, err
 	}
 
+	w, err := wal.New(
+		filepath.Join(cfg.DataDir, "wal"),
+		cfg.WALSegmentMaxRecords,
+		cfg.WALSyncMode,
+		time.Duration(cfg.WALSyncIntervalMs)*time.Millisecond,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// sav I/O SSTable-ova ide kroz isti block cache
+	bm := block.NewBlockManager(cfg.CacheSize)
+	sst, err := sstable.New(filepath.Join(cfg.DataDir, "sstable"), cfg, bm)
+	if err != nil {
+		w.Close()
+		return nil, err
+	}
+
 	e := &Engine{
 		cfg: cfg,
-		bm:  block.NewBlockManager(cfg.CacheSize),
-		wal: wal.New(),
+		bm:  bm,
+		wal: w,
 		mem: mem,
-		sst: sstable.New(filepath.Join(cfg.DataDir, "sstable", "level0"), cfg.MultiFileSSTable),
+		sst: sst,
+	}
+	e.snaps.open = make(map[uint64]int)
+	e.writeCond = sync.NewCond(&e.writeMu)
+	e.flushCh = make(chan struct{}, 1)
+	e.flushDone = make(chan struct{})
+	e.flushCond = sync.NewCond(&e.flushMu)
+	e.compactCh = make(chan struct{}, 1)
+	e.compactDone = make(chan struct{})
+	e.compactStop = make(chan struct{})
+
+	// seq = max(sacuvani seq, checkpoint, najveci seq u SSTable-ovima);
+	// replay ga posle pomera i preko zapisa iz WAL-a
+	e.seq.Store(max(loadSeq(cfg.DataDir), e.wal.CheckpointSeq(), e.sst.MaxSeq()))
+
+	// WAL replay -> memtable (zapisi do checkpoint-a su vec u SSTable-ovima)
+	n, err := e.wal.Replay(e.replayRecord)
+	if err != nil {
+		e.closeFiles()
+		return nil, err
 	}
+	e.recovered = n
+	e.snaps.visible = e.seq.Load()
 
-	// TODO: WAL replay -> memtable
-	if err := e.wal.Replay(func(r model.Record) error {
-		// e.mem.Put(...) / Delete...
-		return nil
-	}); err != nil {
+	// odmah zapamti oporavljeni seq, da se ne bi izgubio ako WAL i SSTable-ovi
+	// kasnije izgube zapise sa najvecim seq-om
+	if err := storeSeq(cfg.DataDir, e.seq.Load()); err != nil {
+		e.closeFiles()
 		return nil, err
 	}
 
+	// RO tabele koje su ostale posle replay-a preuzima pozadinski flusher,
+	// a nivoe koji su vec preko granice kompakcija
+	go e.flushLoop()
+	go e.compactLoop()
+	e.triggerFlush()
+	e.triggerCompaction()
+
 	return e, nil
 }
 
-func (e *Engine) Put(key string, value []byte, ttl ...time.Duration) error {
-	e.seq++
-	var expiresAt uint64
-	if len(ttl) > 0 {
-		expiresAt = uint64(time.Now().Add(ttl[0]).Unix())
-	}
-	rec := model.Record{Key: key, Value: value, Tombstone: false, Seq: e.seq, ExpiresAt: expiresAt}
+// closeFiles zatvara WAL i manifest kada otvaranje ne uspe pre pokretanja
+// flusher-a i kompakcije.
+func (e *Engine) closeFiles() {
+	e.sst.Close()
+	e.wal.Close()
+}
 
-	// 1) WAL prvo
-	if err := e.wal.Append(rec); err != nil {
-		return err
-	}
+// RecoveredRecords vraca koliko je zapisa vraceno iz WAL-a pri pokretanju.
+func (e *Engine) RecoveredRecords() int {
+	return e.recovered
+}
 
-	// 2) Memtable
-	flushNeeded, err := e.mem.Put(rec)
-	if err != nil {
-		return err
-	}
+// Stats vraca trenutne brojace.
+func (e *Engine) Stats() Stats {
+	e.compactMu.Lock()
+	compactErr := e.compactErr
+	e.compactMu.Unlock()
 
-	// 3) Flush kad je puna
-	if flushNeeded {
-		return e.flushMemtable()
+	return Stats{
+		WriteStalls:   e.stalls.Load(),
+		Filter:        e.sst.FilterStats(),
+		Compaction:    e.sst.CompactionStats(),
+		CompactionErr: compactErr,
 	}
-	return nil
 }
 
-func (e *Engine) Delete(key string) error {
-	e.seq++
-	rec := model.Record{Key: key, Value: nil, Tombstone: true, Seq: e.seq, ExpiresAt: 0}
+// replayRecord vraca jedan WAL zapis u memtable i pomera seq na najveci vidjeni.
+// Flusher jos nije pokrenut, pa se flush ovde radi sinhrono.
+func (e *Engine) replayRecord(rec model.Record) error {
+	if rec.Seq > e.seq.Load() {
+		e.seq.Store(rec.Seq)
+	}
+	for e.mem.Stalled() {
+		if _, err := e.flushMemtable(); err != nil {
+			return err
+		}
+	}
+	_, err := e.memApply(rec)
+	return err
+}
 
-	if err := e.wal.Append(rec); err != nil {
+// applyRecords upisuje zapise u memtable, vise njih atomski; ako nema
+// slobodne tabele ceka flusher. Zapisi postaju vidljivi novim snapshot-ima
+// tek kada su svi primenjeni.
+func (e *Engine) applyRecords(recs []model.Record) error {
+	if err := e.waitWritable(); err != nil {
 		return err
 	}
-	flushNeeded, err := e.mem.Delete(rec)
+	e.snaps.mu.Lock()
+	flushNeeded, err := e.mem.Apply(recs, e.openSnapshots())
+	if err == nil {
+		e.snaps.visible = recs[len(recs)-1].Seq
+	}
+	e.snaps.mu.Unlock()
 	if err != nil {
 		return err
 	}
 	if flushNeeded {
-		return e.flushMemtable()
+		e.triggerFlush()
 	}
 	return nil
 }
 
-func (e *Engine) Get(key string) ([]byte, bool, error) {
-	// 1) Memtable
-	r := e.mem.Get(key)
-	if r.Found {
-		if r.Tombstone {
-			return nil, false, nil
-		}
-		return r.Value, true, nil
+// memApply vraca zapis pri replay-u, kada jos nema snapshot-a.
+func (e *Engine) memApply(rec model.Record) (bool, error) {
+	if rec.Tombstone {
+		return e.mem.Delete(rec, nil)
 	}
+	return e.mem.Put(rec, nil)
+}
 
-	// 2) SSTable
-	r = e.sst.Get(key)
-	if r.Found {
-		if r.Tombstone {
-			return nil, false, nil
-		}
-		return r.Value, true, nil
+// Put i Delete su bezbedni za konkurentno koriscenje; seq dodeljuje write pipeline.
+func (e *Engine) Put(key string, value []byte, ttl ...time.Duration) error {
+	var expiresAt uint64
+	if len(ttl) > 0 {
+		expiresAt = uint64(time.Now().Add(ttl[0]).Unix())
 	}
+	return e.write(model.Record{Key: key, Value: value, Tombstone: false, ExpiresAt: expiresAt})
+}
 
-	return nil, false, nil
+func (e *Engine) Delete(key string) error {
+	return e.write(model.Record{Key: key, Value: nil, Tombstone: true, ExpiresAt: 0})
 }
 
-func (e *Engine) flushMemtable() error {
-	records, ok := e.mem.NextFlushBatch()
-	if !ok {
-		return nil
+// VerifySSTables proverava integritet svih SSTable-ova preko njihovih merkle stabala.
+func (e *Engine) VerifySSTables() ([]sstable.VerifyResult, error) {
+	if e.closed.Load() {
+		return nil, ErrClosed
 	}
-	if err := e.sst.Flush(records); err != nil {
-		return err
+	return e.sst.Verify(), nil
+}
+
+// Get vraca najnoviju verziju kljuca. Obrisan ili istekao kljuc se vodi kao
+// nepostojeci, i tada zaklanja starije verzije u nizim slojevima.
+func (e *Engine) Get(key string) ([]byte, bool, error) {
+	return e.get(key, math.MaxUint64)
+}
+
+// get vraca najnoviju verziju kljuca sa seq <= maxSeq.
+func (e *Engine) get(key string, maxSeq uint64) ([]byte, bool, error) {
+	if e.closed.Load() {
+		return nil, false, ErrClosed
 	}
-	return nil
+
+	// 1) Memtable
+	r := e.mem.Get(key, maxSeq)
+	if !r.Found {
+		// 2) SSTable
+		var err error
+		if r, err = e.sst.Get(key, maxSeq); err != nil {
+			return nil, false, err
+		}
+	}
+
+	if !r.Found || r.Tombstone || r.Expired(time.Now()) {
+		return nil, false, nil
+	}
+	return r.Value, true, nil
 }
@@ -0,0 +1,136 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"kv-engine/internal/config"
+)
+
+func testConfig(dir string) config.Config {
+	cfg := config.Default()
+	cfg.DataDir = dir
+	cfg.MemtableMaxEntries = 4
+	cfg.MemtableMaxBytes = 1 << 20
+	return cfg
+}
+
+func openTestEngine(t *testing.T, cfg config.Config) *Engine {
+	t.Helper()
+	e, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return e
+}
+
+func mustGet(t *testing.T, get func(string) ([]byte, bool, error), key, want string) {
+	t.Helper()
+	v, ok, err := get(key)
+	if err != nil {
+		t.Fatalf("Get(%s): %v", key, err)
+	}
+	if want == "" {
+		if ok {
+			t.Fatalf("Get(%s) = %q, want not found", key, v)
+		}
+		return
+	}
+	if !ok || string(v) != want {
+		t.Fatalf("Get(%s) = %q, %v, want %q", key, v, ok, want)
+	}
+}
+
+func TestFailedApplyStopsWritesAndRecovers(t *testing.T) {
+	dir := t.TempDir()
+	cfg := testConfig(dir)
+	e := openTestEngine(t, cfg)
+
+	// flush ne moze da napravi level0, pa memtable ostaje puna
+	level0 := filepath.Join(dir, "sstable", "level0")
+	os.RemoveAll(level0)
+	os.WriteFile(level0, nil, 0644)
+
+	var written []string
+	for i := 0; ; i++ {
+		key := fmt.Sprintf("k%d", i)
+		err := e.Put(key, []byte("v"))
+		if err != nil {
+			if !errors.Is(err, ErrFailed) {
+				t.Fatalf("Put(%s): %v, want ErrFailed", key, err)
+			}
+			// zapis je ipak trajan u WAL-u
+			written = append(written, key)
+			break
+		}
+		written = append(written, key)
+		if i > 100 {
+			t.Fatal("writes never failed")
+		}
+	}
+	if err := e.Put("after", []byte("v")); !errors.Is(err, ErrFailed) {
+		t.Fatalf("Put after failure: %v, want ErrFailed", err)
+	}
+	mustGet(t, e.Get, written[0], "v")
+	e.Close(context.Background())
+
+	os.Remove(level0)
+	e = openTestEngine(t, cfg)
+	defer e.Close(context.Background())
+	for _, key := range written {
+		mustGet(t, e.Get, key, "v")
+	}
+	mustGet(t, e.Get, "after", "")
+}
+
+// waitFor ceka da cond postane tacan (pozadinski flush i kompakcija).
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func putKeys(t *testing.T, e *Engine, from, n int) {
+	t.Helper()
+	for i := from; i < from+n; i++ {
+		if err := e.Put(fmt.Sprintf("k%03d", i), []byte(fmt.Sprintf("v%d", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCompactionErrorInStats(t *testing.T) {
+	dir := t.TempDir()
+	e := openTestEngine(t, testConfig(dir))
+	defer e.Close(context.Background())
+
+	level0 := filepath.Join(dir, "sstable", "level0")
+	tables := func() []string {
+		files, _ := filepath.Glob(filepath.Join(level0, "*.data"))
+		return files
+	}
+	putKeys(t, e, 0, 13)
+	waitFor(t, "three L0 tables", func() bool { return len(tables()) == 3 })
+
+	// keyLen prvog zapisa pokazuje iza kraja data dela
+	path := tables()[0]
+	b, _ := os.ReadFile(path)
+	b[0] = 100
+	os.WriteFile(path, b, 0644)
+
+	putKeys(t, e, 13, 4)
+	waitFor(t, "compaction error", func() bool { return e.Stats().CompactionErr != nil })
+	if n := len(tables()); n != 4 {
+		t.Fatalf("L0 tables = %d, want 4", n)
+	}
+}
@@ -0,0 +1,82 @@
+package engine
+
+// triggerFlush budi flusher; signal se ne gubi ako flusher trenutno radi.
+func (e *Engine) triggerFlush() {
+	select {
+	case e.flushCh <- struct{}{}:
+	default:
+	}
+}
+
+// flushLoop prazni roQueue memtable manager-a cim se neka tabela zamrzne.
+// Zavrsava se kada Close zatvori flushCh.
+func (e *Engine) flushLoop() {
+	defer close(e.flushDone)
+
+	for range e.flushCh {
+		for {
+			flushed, err := e.flushMemtable()
+
+			e.flushMu.Lock()
+			e.flushErr = err
+			e.flushCond.Broadcast()
+			e.flushMu.Unlock()
+
+			if err != nil || !flushed {
+				break
+			}
+		}
+	}
+}
+
+// waitWritable blokira upis samo dok su sve memtable tabele RO i cekaju flush.
+func (e *Engine) waitWritable() error {
+	if !e.mem.Stalled() {
+		return nil
+	}
+	e.stalls.Add(1)
+
+	e.flushMu.Lock()
+	defer e.flushMu.Unlock()
+	for e.mem.Stalled() {
+		if e.flushErr != nil {
+			return e.flushErr
+		}
+		e.triggerFlush()
+		e.flushCond.Wait()
+	}
+	return nil
+}
+
+// flushMemtable upisuje najstariju RO tabelu u SSTable. Vraca false ako
+// nije bilo sta da se flush-uje.
+func (e *Engine) flushMemtable() (bool, error) {
+	records, ok := e.mem.NextFlushBatch()
+	if !ok {
+		return false, nil
+	}
+	if len(records) == 0 {
+		// prazna tabela (FreezeActive pri gasenju), nema sta da se pise
+		e.mem.FlushDone()
+		return true, nil
+	}
+	if err := e.sst.Flush(records); err != nil {
+		return false, err
+	}
+	// tek sada tabela sme da nestane iz memorije, zapisi su vidljivi u SSTable-u
+	e.mem.FlushDone()
+	e.triggerCompaction()
+
+	// RO tabele se flush-uju FIFO redom, pa su svi zapisi sa seq <= maxSeq
+	// sada u SSTable-ovima i WAL segmenti koji ih pokrivaju mogu da se obrisu
+	var maxSeq uint64
+	for _, r := range records {
+		if r.Seq > maxSeq {
+			maxSeq = r.Seq
+		}
+	}
+	if err := e.wal.Checkpoint(maxSeq); err != nil {
+		return true, err
+	}
+	return true, storeSeq(e.cfg.DataDir, e.seq.Load())
+}
@@ -0,0 +1,41 @@
+package engine
+
+import (
+	"math"
+	"time"
+
+	"kv-engine/internal/iterator"
+)
+
+// IteratorOptions ogranicava kljuceve koje iterator vidi.
+type IteratorOptions struct {
+	LowerBound string // najmanji kljuc (ukljucivo); "" = od prvog kljuca
+	UpperBound string // kljucevi su strogo manji od njega; "" = do poslednjeg kljuca
+}
+
+// NewIterator vraca iterator koji redom kljuceva spaja active i RO memtable
+// tabele i sve nivoe SSTable-ova. Za svaki kljuc vidi se najnovija verzija;
+// obrisani i istekli kljucevi se preskacu. Memtable tabele se kopiraju pri
+// pravljenju iteratora, a SSTable-ovi koje je uzeo ostaju na disku dok se ne
+// zatvori, pa kasniji upisi ne menjaju ono sto vidi. Iterator se mora
+// zatvoriti sa Close.
+func (e *Engine) NewIterator(opts IteratorOptions) (*iterator.Iterator, error) {
+	return e.newIterator(opts, math.MaxUint64)
+}
+
+// newIterator vraca iterator koji vidi samo verzije sa seq <= maxSeq.
+func (e *Engine) newIterator(opts IteratorOptions, maxSeq uint64) (*iterator.Iterator, error) {
+	if e.closed.Load() {
+		return nil, ErrClosed
+	}
+
+	// memtable pre SSTable-ova: tabela koja se izmedju flush-uje je tako
+	// vidljiva bar u jednom od dva izvora
+	var srcs []iterator.Source
+	for _, recs := range e.mem.SortedTables() {
+		srcs = append(srcs, iterator.NewSliceSource(recs))
+	}
+	srcs = append(srcs, e.sst.Sources(opts.LowerBound, opts.UpperBound)...)
+
+	return iterator.New(srcs, opts.LowerBound, opts.UpperBound, maxSeq, time.Now()), nil
+}
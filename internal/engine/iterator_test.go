@@ -0,0 +1,64 @@
+package engine
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestIteratorBounds(t *testing.T) {
+	dir := t.TempDir()
+	e := openTestEngine(t, testConfig(dir))
+	defer e.Close(context.Background())
+
+	// stariji kljucevi zavrse u SSTable-ovima, noviji ostaju u memtable-u
+	for _, key := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+		e.Put(key, []byte(key))
+	}
+	waitFor(t, "flush", func() bool {
+		files, _ := filepath.Glob(filepath.Join(dir, "sstable", "level0", "*.data"))
+		return len(files) > 0
+	})
+	e.Delete("c")
+	e.Put("d", []byte("d2"))
+
+	it, err := e.NewIterator(IteratorOptions{LowerBound: "b", UpperBound: "f"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer it.Close()
+
+	expect := func(step string, ok bool, want string) {
+		t.Helper()
+		if want == "" {
+			if ok || it.Valid() {
+				t.Fatalf("%s: at %q, want end", step, it.Key())
+			}
+			return
+		}
+		if !ok || it.Key() != want {
+			t.Fatalf("%s: ok=%v key=%q, want %q", step, ok, it.Key(), want)
+		}
+	}
+
+	expect("First", it.First(), "b")
+	expect("Prev at lower bound", it.Prev(), "")
+	expect("Last", it.Last(), "e")
+	expect("Next at upper bound", it.Next(), "")
+	expect("Seek below lower bound", it.Seek("a"), "b")
+	expect("Seek deleted key", it.Seek("c"), "d")
+	if string(it.Value()) != "d2" {
+		t.Fatalf("d = %q, want d2", it.Value())
+	}
+	expect("Seek upper bound", it.Seek("f"), "")
+
+	expect("Last", it.Last(), "e")
+	expect("Prev", it.Prev(), "d")
+	expect("Next", it.Next(), "e")
+	expect("Prev", it.Prev(), "d")
+	expect("Prev", it.Prev(), "b")
+	expect("Next", it.Next(), "d")
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,148 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+
+	"kv-engine/internal/model"
+)
+
+// ErrFailed vracaju upisi kada zapisi koji su vec trajni u WAL-u nisu mogli
+// da se primene na memtable. Engine tada odbija sve dalje upise (citanja
+// vide stanje pre neuspelog upisa), a zapisi se vracaju replay-om pri
+// sledecem pokretanju.
+var ErrFailed = errors.New("engine: write is durable but could not be applied; reopen the engine")
+
+// writeReq je jedan upis koji ceka u redu write pipeline-a.
+type writeReq struct {
+	recs  []model.Record
+	batch bool // recs se upisuju atomski (jedan WAL zapis, vidljivi odjednom)
+	// check, ako postoji, lider poziva pre dodele seq-a; greska odbija samo
+	// ovaj upis (videti Txn.check)
+	check func(written map[string]bool) error
+	err   error
+	done  bool
+}
+
+// write ubacuje zapise u red i ceka da budu upisani. Prvi u redu je lider:
+// preuzima sve upise koji trenutno cekaju, dodeljuje im seq, upisuje ih u WAL
+// jednim fsync-om i primenjuje na memtable. Ostali samo cekaju rezultat.
+func (e *Engine) write(recs ...model.Record) error {
+	return e.submit(&writeReq{recs: recs})
+}
+
+// writeBatch upisuje zapise atomski, kao jedan batch.
+func (e *Engine) writeBatch(recs []model.Record) error {
+	return e.submit(&writeReq{recs: recs, batch: true})
+}
+
+func (e *Engine) submit(req *writeReq) error {
+	e.writeMu.Lock()
+	if e.closed.Load() {
+		e.writeMu.Unlock()
+		return ErrClosed
+	}
+	if e.failed != nil {
+		e.writeMu.Unlock()
+		return e.failed
+	}
+	e.writers = append(e.writers, req)
+	for !req.done && e.writers[0] != req {
+		e.writeCond.Wait()
+	}
+	if req.done {
+		e.writeMu.Unlock()
+		return req.err
+	}
+	group := append([]*writeReq(nil), e.writers...)
+	// prethodni lider je mogao da prebaci engine u ErrFailed
+	err := e.failed
+	e.writeMu.Unlock()
+
+	if err == nil {
+		err = e.commitGroup(group)
+	}
+
+	e.writeMu.Lock()
+	for _, r := range group {
+		if r.err == nil {
+			r.err = err
+		}
+		r.done = true
+	}
+	e.writers = e.writers[len(group):]
+	e.writeCond.Broadcast()
+	e.writeMu.Unlock()
+	return req.err
+}
+
+// commitGroup izvrsava samo lider, pa se seq-ovi dodeljuju bez rupa i redom.
+// Batch dobija uzastopne seq-ove i ide u WAL kao jedan zapis. Upis koji
+// check odbije ne dobija seq i ne ide u WAL. Ako primena na memtable ne
+// uspe posle trajnog upisa u WAL, engine prelazi u ErrFailed: upisi koji su
+// vec primenjeni uspevaju, a ostali dobijaju ErrFailed.
+func (e *Engine) commitGroup(group []*writeReq) error {
+	// kljucevi upisani ranije u grupi, samo ako ih neki check gleda
+	var written map[string]bool
+	for _, r := range group {
+		if r.check != nil {
+			written = make(map[string]bool)
+			break
+		}
+	}
+
+	var groups [][]model.Record
+	var owners []*writeReq // upis kome pripada svaka grupa
+	for _, r := range group {
+		if r.check != nil {
+			if r.err = r.check(written); r.err != nil {
+				continue
+			}
+		}
+		for i := range r.recs {
+			r.recs[i].Seq = e.seq.Add(1)
+			if written != nil {
+				written[r.recs[i].Key] = true
+			}
+		}
+		if r.batch {
+			groups = append(groups, r.recs)
+			owners = append(owners, r)
+			continue
+		}
+		for i := range r.recs {
+			groups = append(groups, r.recs[i:i+1])
+			owners = append(owners, r)
+		}
+	}
+
+	if len(groups) == 0 {
+		return nil
+	}
+
+	// 1) WAL prvo
+	if err := e.wal.AppendGroups(groups...); err != nil {
+		return err
+	}
+
+	// 2) Memtable (i flush kad je puna)
+	for i, g := range groups {
+		if err := e.applyRecords(g); err != nil {
+			e.fail(err, owners[i:])
+			return nil
+		}
+	}
+	return nil
+}
+
+// fail prebacuje engine u ErrFailed i javlja gresku upisima koji nisu
+// primenjeni.
+func (e *Engine) fail(err error, pending []*writeReq) {
+	failed := fmt.Errorf("%w: %v", ErrFailed, err)
+	e.writeMu.Lock()
+	e.failed = failed
+	e.writeMu.Unlock()
+	for _, r := range pending {
+		r.err = failed
+	}
+}
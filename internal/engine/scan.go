@@ -0,0 +1,55 @@
+package engine
+
+// KV je jedan par kljuc-vrednost iz skeniranja.
+type KV struct {
+	Key   string
+	Value []byte
+}
+
+// Scan vraca najvise limit zivih kljuceva iz [start, end], sortirano po
+// kljucu (limit <= 0 = bez ogranicenja). Prazan end znaci do poslednjeg
+// kljuca.
+func (e *Engine) Scan(start, end string, limit int) ([]KV, error) {
+	opts := IteratorOptions{LowerBound: start}
+	if end != "" {
+		// najmanji kljuc veci od end, da end bude ukljucen
+		opts.UpperBound = end + "\x00"
+	}
+	return e.collect(opts, limit)
+}
+
+// PrefixScan vraca najvise limit zivih kljuceva koji pocinju sa prefix,
+// sortirano po kljucu (limit <= 0 = bez ogranicenja).
+func (e *Engine) PrefixScan(prefix string, limit int) ([]KV, error) {
+	return e.collect(IteratorOptions{LowerBound: prefix, UpperBound: prefixEnd(prefix)}, limit)
+}
+
+func (e *Engine) collect(opts IteratorOptions, limit int) ([]KV, error) {
+	it, err := e.NewIterator(opts)
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+
+	var out []KV
+	for ok := it.First(); ok && (limit <= 0 || len(out) < limit); ok = it.Next() {
+		out = append(out, KV{Key: it.Key(), Value: it.Value()})
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// prefixEnd vraca najmanji kljuc veci od svih kljuceva sa datim prefiksom,
+// ili "" ako takav ne postoji (prefiks od samih 0xff bajtova).
+func prefixEnd(prefix string) string {
+	b := []byte(prefix)
+	for i := len(b) - 1; i >= 0; i-- {
+		if b[i] < 0xff {
+			b[i]++
+			return string(b[:i+1])
+		}
+	}
+	return ""
+}
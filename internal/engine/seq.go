@@ -0,0 +1,33 @@
+package engine
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+
+	"kv-engine/internal/fileutil"
+)
+
+// SEQ fajl u DataDir-u cuva poslednji izdati seq: [seq u64][crc u32].
+const seqFile = "SEQ"
+
+func storeSeq(dataDir string, seq uint64) error {
+	buf := make([]byte, 12)
+	binary.BigEndian.PutUint64(buf[0:8], seq)
+	binary.BigEndian.PutUint32(buf[8:12], crc32.ChecksumIEEE(buf[0:8]))
+	return fileutil.WriteFileAtomic(filepath.Join(dataDir, seqFile), buf)
+}
+
+// loadSeq vraca 0 ako fajl ne postoji ili je ostecen; tada seq dolazi
+// samo iz WAL-a i SSTable-ova.
+func loadSeq(dataDir string) uint64 {
+	buf, err := os.ReadFile(filepath.Join(dataDir, seqFile))
+	if err != nil || len(buf) != 12 {
+		return 0
+	}
+	if crc32.ChecksumIEEE(buf[0:8]) != binary.BigEndian.Uint32(buf[8:12]) {
+		return 0
+	}
+	return binary.BigEndian.Uint64(buf[0:8])
+}
@@ -0,0 +1,90 @@
+package engine
+
+import (
+	"slices"
+	"sync"
+
+	"kv-engine/internal/iterator"
+)
+
+// snapshots su seq-ovi otvorenih snapshot-a. Memtable i kompakcija cuvaju
+// starije verzije kljuca dok god ih neki od njih vidi.
+type snapshots struct {
+	mu      sync.Mutex     // drzi ga i lider dok primenjuje grupu na memtable
+	visible uint64         // seq poslednjeg zapisa primenjenog na memtable
+	open    map[uint64]int // seq -> broj otvorenih snapshot-a na njemu
+}
+
+// Snapshot je pogled na podatke kakvi su bili u trenutku kada je napravljen.
+// Dok je otvoren, starije verzije koje vidi ostaju u memtable-u i SSTable-ovima,
+// pa ga treba zatvoriti sa Close cim vise nije potreban. Bezbedan je za
+// konkurentno koriscenje.
+type Snapshot struct {
+	e    *Engine
+	seq  uint64
+	once sync.Once
+}
+
+// Snapshot pravi snapshot na poslednjem upisu koji je vidljiv citanjima.
+func (e *Engine) Snapshot() (*Snapshot, error) {
+	if e.closed.Load() {
+		return nil, ErrClosed
+	}
+	e.snaps.mu.Lock()
+	defer e.snaps.mu.Unlock()
+	seq := e.snaps.visible
+	e.snaps.open[seq]++
+	return &Snapshot{e: e, seq: seq}, nil
+}
+
+// Seq vraca seq poslednjeg upisa koji snapshot vidi.
+func (s *Snapshot) Seq() uint64 {
+	return s.seq
+}
+
+// Get vraca vrednost kljuca kakva je bila u trenutku snapshot-a. TTL se
+// proverava u odnosu na trenutno vreme.
+func (s *Snapshot) Get(key string) ([]byte, bool, error) {
+	return s.e.get(key, s.seq)
+}
+
+// NewIterator vraca iterator koji vidi podatke kakvi su bili u trenutku
+// snapshot-a; inace radi kao Engine.NewIterator.
+func (s *Snapshot) NewIterator(opts IteratorOptions) (*iterator.Iterator, error) {
+	return s.e.newIterator(opts, s.seq)
+}
+
+// Close pusta verzije koje je snapshot cuvao: iz memtable-a nestaju pri
+// sledecem upisu istog kljuca, a iz SSTable-ova pri kompakciji. Visestruki
+// Close nema efekta.
+func (s *Snapshot) Close() error {
+	s.once.Do(func() {
+		e := s.e
+		e.snaps.mu.Lock()
+		defer e.snaps.mu.Unlock()
+		if e.snaps.open[s.seq]--; e.snaps.open[s.seq] == 0 {
+			delete(e.snaps.open, s.seq)
+		}
+	})
+	return nil
+}
+
+// snapshotList vraca seq-ove otvorenih snapshot-a, rastuce.
+func (e *Engine) snapshotList() []uint64 {
+	e.snaps.mu.Lock()
+	defer e.snaps.mu.Unlock()
+	return e.openSnapshots()
+}
+
+// openSnapshots je snapshotList za pozivaoca koji vec drzi snaps.mu.
+func (e *Engine) openSnapshots() []uint64 {
+	if len(e.snaps.open) == 0 {
+		return nil
+	}
+	out := make([]uint64, 0, len(e.snaps.open))
+	for seq := range e.snaps.open {
+		out = append(out, seq)
+	}
+	slices.Sort(out)
+	return out
+}
@@ -0,0 +1,51 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestSnapshotAcrossFlushAndCompaction(t *testing.T) {
+	e := openTestEngine(t, testConfig(t.TempDir()))
+	defer e.Close(context.Background())
+
+	e.Put("a", []byte("old"))
+	e.Put("b", []byte("old"))
+	snap, err := e.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer snap.Close()
+
+	e.Put("a", []byte("new"))
+	e.Delete("b")
+	e.Put("c", []byte("new"))
+	// dovoljno upisa za cetiri flush-a, pa L0 -> L1 kompakciju
+	putKeys(t, e, 0, 20)
+	waitFor(t, "compaction", func() bool { return e.Stats().Compaction.Compactions > 0 })
+	if err := e.Stats().CompactionErr; err != nil {
+		t.Fatal(err)
+	}
+
+	mustGet(t, snap.Get, "a", "old")
+	mustGet(t, snap.Get, "b", "old")
+	mustGet(t, snap.Get, "c", "")
+	mustGet(t, snap.Get, "k000", "")
+	mustGet(t, e.Get, "a", "new")
+	mustGet(t, e.Get, "b", "")
+	mustGet(t, e.Get, "c", "new")
+
+	it, err := snap.NewIterator(IteratorOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer it.Close()
+	var got []string
+	for ok := it.First(); ok; ok = it.Next() {
+		got = append(got, it.Key()+"="+string(it.Value()))
+	}
+	if fmt.Sprint(got) != "[a=old b=old]" || it.Err() != nil {
+		t.Fatalf("snapshot iterator = %v, err=%v", got, it.Err())
+	}
+}
@@ -0,0 +1,138 @@
+package engine
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"kv-engine/internal/model"
+)
+
+var (
+	// ErrConflict vraca Commit kada je neki kljuc koji je transakcija procitala
+	// u medjuvremenu promenjen; nista od transakcije nije upisano.
+	ErrConflict = errors.New("engine: transaction conflict")
+	// ErrTxnDone vracaju operacije nad transakcijom posle Commit-a ili Rollback-a.
+	ErrTxnDone = errors.New("engine: transaction already finished")
+)
+
+// Txn je optimisticka transakcija: cita podatke kakvi su bili na pocetku (preko
+// snapshot-a), a upise cuva kod sebe do Commit-a. Commit upisuje sve
+// atomski, kao WriteBatch, osim ako je neki procitani kljuc u medjuvremenu
+// promenjen. Nije bezbedna za konkurentno koriscenje.
+type Txn struct {
+	snap   *Snapshot
+	reads  map[string]bool
+	writes map[string]model.Record // poslednji upis svakog kljuca, za Get
+	batch  WriteBatch
+	done   bool
+}
+
+// BeginTxn pocinje transakciju na poslednjem upisu koji je vidljiv citanjima.
+// Transakcija se zavrsava sa Commit ili Rollback.
+func (e *Engine) BeginTxn() (*Txn, error) {
+	snap, err := e.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+	return &Txn{snap: snap, reads: make(map[string]bool), writes: make(map[string]model.Record)}, nil
+}
+
+// Seq vraca seq na kome je transakcija pocela.
+func (t *Txn) Seq() uint64 {
+	return t.snap.Seq()
+}
+
+// Get vraca vrednost kljuca: sopstveni upis ako postoji, inace vrednost sa
+// pocetka transakcije. Procitani kljucevi se proveravaju pri Commit-u.
+func (t *Txn) Get(key string) ([]byte, bool, error) {
+	if t.done {
+		return nil, false, ErrTxnDone
+	}
+	if r, ok := t.writes[key]; ok {
+		if r.Tombstone || r.Expired(time.Now()) {
+			return nil, false, nil
+		}
+		return r.Value, true, nil
+	}
+	t.reads[key] = true
+	return t.snap.Get(key)
+}
+
+// Put dodaje upis kljuca; ttl je opcioni kao kod Engine.Put.
+func (t *Txn) Put(key string, value []byte, ttl ...time.Duration) {
+	t.batch.Put(key, value, ttl...)
+	t.writes[key] = t.batch.recs[t.batch.Len()-1]
+}
+
+// Delete dodaje brisanje kljuca.
+func (t *Txn) Delete(key string) {
+	t.batch.Delete(key)
+	t.writes[key] = t.batch.recs[t.batch.Len()-1]
+}
+
+// Commit atomski upisuje sve upise transakcije, ili vraca ErrConflict ako
+// neki procitani kljuc ima verziju noviju od pocetka transakcije. Provera i
+// upis idu kroz write pipeline, pa izmedju njih nema drugih upisa.
+// Transakcija je posle Commit-a zavrsena i kada vrati gresku.
+func (t *Txn) Commit() error {
+	if t.done {
+		return ErrTxnDone
+	}
+	t.done = true
+	defer t.snap.Close()
+
+	e := t.snap.e
+	if e.closed.Load() {
+		return ErrClosed
+	}
+	if t.batch.Len() == 0 {
+		// samo citanja: snapshot je vec dao dosledan pogled
+		return nil
+	}
+	return e.submit(&writeReq{
+		recs:  append([]model.Record(nil), t.batch.recs...),
+		batch: true,
+		check: t.check,
+	})
+}
+
+// Rollback odbacuje transakciju. Posle Commit-a nema efekta.
+func (t *Txn) Rollback() {
+	if t.done {
+		return
+	}
+	t.done = true
+	t.snap.Close()
+}
+
+// check izvrsava lider; written su kljucevi koje upisuju raniji upisi iz
+// iste grupe, koji jos nisu u memtable-u.
+func (t *Txn) check(written map[string]bool) error {
+	for key := range t.reads {
+		if written[key] {
+			return ErrConflict
+		}
+		changed, err := t.snap.e.modifiedSince(key, t.snap.seq)
+		if err != nil {
+			return err
+		}
+		if changed {
+			return ErrConflict
+		}
+	}
+	return nil
+}
+
+// modifiedSince kaze da li kljuc ima verziju sa seq-om vecim od seq. Sme da
+// je zove samo lider write pipeline-a, kada su svi raniji upisi primenjeni.
+func (e *Engine) modifiedSince(key string, seq uint64) (bool, error) {
+	r := e.mem.Get(key, math.MaxUint64)
+	if !r.Found {
+		var err error
+		if r, err = e.sst.Get(key, math.MaxUint64); err != nil {
+			return false, err
+		}
+	}
+	return r.Found && r.Seq > seq, nil
+}
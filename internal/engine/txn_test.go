@@ -0,0 +1,80 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestTxnConflict(t *testing.T) {
+	e := openTestEngine(t, testConfig(t.TempDir()))
+	defer e.Close(context.Background())
+	e.Put("x", []byte("0"))
+
+	txn, err := e.BeginTxn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustGet(t, txn.Get, "x", "0")
+	txn.Put("x", []byte("txn"))
+	txn.Put("y", []byte("txn"))
+
+	// upis procitanog kljuca posle pocetka transakcije
+	e.Put("x", []byte("other"))
+
+	if err := txn.Commit(); !errors.Is(err, ErrConflict) {
+		t.Fatalf("Commit: %v, want ErrConflict", err)
+	}
+	mustGet(t, e.Get, "x", "other")
+	mustGet(t, e.Get, "y", "")
+	if err := txn.Commit(); !errors.Is(err, ErrTxnDone) {
+		t.Fatalf("second Commit: %v, want ErrTxnDone", err)
+	}
+}
+
+func TestTxnCommit(t *testing.T) {
+	e := openTestEngine(t, testConfig(t.TempDir()))
+	defer e.Close(context.Background())
+	e.Put("x", []byte("0"))
+	e.Put("gone", []byte("0"))
+
+	txn, _ := e.BeginTxn()
+	mustGet(t, txn.Get, "x", "0")
+	txn.Put("x", []byte("1"))
+	txn.Delete("gone")
+	// sopstveni upisi su vidljivi u transakciji, ali ne i van nje
+	mustGet(t, txn.Get, "x", "1")
+	mustGet(t, txn.Get, "gone", "")
+	mustGet(t, e.Get, "x", "0")
+
+	// upis kljuca koji transakcija nije procitala nije konflikt
+	e.Put("unread", []byte("other"))
+
+	if err := txn.Commit(); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	mustGet(t, e.Get, "x", "1")
+	mustGet(t, e.Get, "gone", "")
+	mustGet(t, e.Get, "unread", "other")
+}
+
+func TestReadOnlyTxn(t *testing.T) {
+	e := openTestEngine(t, testConfig(t.TempDir()))
+	defer e.Close(context.Background())
+	e.Put("x", []byte("0"))
+
+	txn, _ := e.BeginTxn()
+	mustGet(t, txn.Get, "x", "0")
+	e.Put("x", []byte("1"))
+	e.Put("new", []byte("1"))
+
+	// citanja vide stanje sa pocetka, a commit bez upisa nema konflikt
+	mustGet(t, txn.Get, "x", "0")
+	mustGet(t, txn.Get, "new", "")
+	if err := txn.Commit(); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	if _, _, err := txn.Get("x"); !errors.Is(err, ErrTxnDone) {
+		t.Fatalf("Get after Commit: %v, want ErrTxnDone", err)
+	}
+}
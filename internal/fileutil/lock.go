@@ -0,0 +1,37 @@
+package fileutil
+
+import (
+	"errors"
+	"os"
+	"strconv"
+)
+
+// ErrLocked znaci da lock vec drzi drugi proces.
+var ErrLocked = errors.New("file is locked by another process")
+
+// FileLock je ekskluzivni lock nad fajlom koji traje dok se ne pozove
+// Unlock ili dok proces ne umre (OS ga tada sam oslobadja).
+type FileLock struct {
+	f *os.File
+}
+
+// Unlock oslobadja lock. Sam fajl ostaje na disku.
+func (l *FileLock) Unlock() error {
+	if l == nil || l.f == nil {
+		return nil
+	}
+	err := unlockFile(l.f)
+	if cerr := l.f.Close(); err == nil {
+		err = cerr
+	}
+	l.f = nil
+	return err
+}
+
+// writePid upisuje pid vlasnika u lock fajl, samo radi lakseg debug-a.
+func writePid(f *os.File) {
+	if err := f.Truncate(0); err != nil {
+		return
+	}
+	_, _ = f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+}
@@ -0,0 +1,41 @@
+//go:build windows
+
+package fileutil
+
+import (
+	"os"
+	"syscall"
+)
+
+const errorSharingViolation syscall.Errno = 32
+
+// Lock otvara path bez deljenja (share mode 0), pa ga drugi proces ne moze
+// otvoriti dok je lock uzet.
+func Lock(path string) (*FileLock, error) {
+	p, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, err
+	}
+	h, err := syscall.CreateFile(p,
+		syscall.GENERIC_READ|syscall.GENERIC_WRITE,
+		0, // bez deljenja
+		nil,
+		syscall.OPEN_ALWAYS,
+		syscall.FILE_ATTRIBUTE_NORMAL,
+		0,
+	)
+	if err != nil {
+		if err == errorSharingViolation {
+			return nil, ErrLocked
+		}
+		return nil, err
+	}
+	f := os.NewFile(uintptr(h), path)
+	writePid(f)
+	return &FileLock{f: f}, nil
+}
+
+// lock nestaje zatvaranjem handle-a u Unlock-u
+func unlockFile(_ *os.File) error {
+	return nil
+}
@@ -0,0 +1,208 @@
+package iterator
+
+import (
+	"errors"
+	"time"
+
+	"kv-engine/internal/model"
+)
+
+// Iterator spaja vise izvora u jedan niz kljuceva, sortiran rastuce, i za
+// svaki kljuc daje samo najnoviju verziju (najveci seq; pri istom seq-u onu
+// iz ranijeg izvora) medju verzijama sa seq <= maxSeq. Kljucevi cija je
+// takva verzija tombstone ili je istekla, ili koji je nemaju, se preskacu.
+//
+// Izvori su uvek namesteni iza tekuceg kljuca u smeru kretanja: pri kretanju
+// napred na prvi zapis sa vecim kljucem, unazad na poslednji sa manjim. Pri
+// promeni smera se ponovo pozicioniraju oko tekuceg kljuca.
+//
+// Iterator nije bezbedan za konkurentno koriscenje.
+type Iterator struct {
+	srcs         []Source // od najnovijeg ka najstarijem
+	lower, upper string   // [lower, upper); "" = bez granice
+	maxSeq       uint64   // novije verzije se ne vide
+	now          time.Time
+
+	forward bool
+	valid   bool
+	rec     model.Record
+	err     error
+}
+
+// New pravi iterator nad izvorima poredjanim od najnovijeg ka najstarijem.
+// Vidljivi su samo kljucevi iz [lower, upper) ("" = bez granice) i verzije
+// sa seq <= maxSeq, a TTL se proverava u odnosu na now. Iterator nije
+// namesten ni na jedan kljuc dok se ne pozove First, Last ili Seek.
+func New(srcs []Source, lower, upper string, maxSeq uint64, now time.Time) *Iterator {
+	return &Iterator{srcs: srcs, lower: lower, upper: upper, maxSeq: maxSeq, now: now}
+}
+
+// First namesta iterator na prvi kljuc.
+func (it *Iterator) First() bool {
+	if it.lower != "" {
+		return it.Seek(it.lower)
+	}
+	for _, s := range it.srcs {
+		s.First()
+	}
+	it.forward = true
+	return it.findNext()
+}
+
+// Last namesta iterator na poslednji kljuc.
+func (it *Iterator) Last() bool {
+	for _, s := range it.srcs {
+		if it.upper != "" {
+			s.SeekLT(it.upper)
+		} else {
+			s.Last()
+		}
+	}
+	it.forward = false
+	return it.findPrev()
+}
+
+// Seek namesta iterator na prvi kljuc >= key.
+func (it *Iterator) Seek(key string) bool {
+	key = max(key, it.lower)
+	for _, s := range it.srcs {
+		s.SeekGE(key)
+	}
+	it.forward = true
+	return it.findNext()
+}
+
+// Next prelazi na sledeci kljuc.
+func (it *Iterator) Next() bool {
+	if !it.valid {
+		return false
+	}
+	if !it.forward {
+		cur := it.rec.Key
+		for _, s := range it.srcs {
+			s.SeekGE(cur)
+			for s.Valid() && s.Record().Key == cur {
+				s.Next()
+			}
+		}
+		it.forward = true
+	}
+	return it.findNext()
+}
+
+// Prev prelazi na prethodni kljuc.
+func (it *Iterator) Prev() bool {
+	if !it.valid {
+		return false
+	}
+	if it.forward {
+		for _, s := range it.srcs {
+			s.SeekLT(it.rec.Key)
+		}
+		it.forward = false
+	}
+	return it.findPrev()
+}
+
+// Valid je true dok je iterator na nekom kljucu.
+func (it *Iterator) Valid() bool {
+	return it.valid
+}
+
+func (it *Iterator) Key() string {
+	return it.rec.Key
+}
+
+func (it *Iterator) Value() []byte {
+	return it.rec.Value
+}
+
+// Err vraca gresku citanja zbog koje je iterator stao.
+func (it *Iterator) Err() error {
+	return it.err
+}
+
+// Close zatvara sve izvore. Iterator posle toga vise nije validan.
+func (it *Iterator) Close() error {
+	var errs []error
+	for _, s := range it.srcs {
+		errs = append(errs, s.Close())
+	}
+	it.srcs = nil
+	it.valid = false
+	return errors.Join(errs...)
+}
+
+func (it *Iterator) findNext() bool {
+	for it.ok() {
+		key, found := "", false
+		for _, s := range it.srcs {
+			if s.Valid() && (!found || s.Record().Key < key) {
+				key, found = s.Record().Key, true
+			}
+		}
+		if !found || (it.upper != "" && key >= it.upper) {
+			break
+		}
+		if rec, have := it.newest(key, Source.Next); it.ok() && have && it.visible(rec) {
+			it.rec, it.valid = rec, true
+			return true
+		}
+	}
+	it.valid = false
+	return false
+}
+
+func (it *Iterator) findPrev() bool {
+	for it.ok() {
+		key, found := "", false
+		for _, s := range it.srcs {
+			if s.Valid() && (!found || s.Record().Key > key) {
+				key, found = s.Record().Key, true
+			}
+		}
+		if !found || key < it.lower {
+			break
+		}
+		if rec, have := it.newest(key, Source.Prev); it.ok() && have && it.visible(rec) {
+			it.rec, it.valid = rec, true
+			return true
+		}
+	}
+	it.valid = false
+	return false
+}
+
+// newest prolazi kroz sve verzije kljuca u svim izvorima (pomerajuci ih sa
+// step) i vraca najnoviju sa seq <= maxSeq; false ako takve nema.
+func (it *Iterator) newest(key string, step func(Source)) (model.Record, bool) {
+	var best model.Record
+	have := false
+	for _, s := range it.srcs {
+		for s.Valid() && s.Record().Key == key {
+			if r := s.Record(); r.Seq <= it.maxSeq && (!have || r.Seq > best.Seq) {
+				best, have = r, true
+			}
+			step(s)
+		}
+	}
+	return best, have
+}
+
+func (it *Iterator) visible(r model.Record) bool {
+	return !r.Tombstone && !r.Expired(it.now)
+}
+
+// ok belezi prvu gresku nekog izvora.
+func (it *Iterator) ok() bool {
+	if it.err != nil {
+		return false
+	}
+	for _, s := range it.srcs {
+		if err := s.Err(); err != nil {
+			it.err = err
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,101 @@
+package iterator
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"kv-engine/internal/model"
+)
+
+func rec(key, value string, seq uint64) model.Record {
+	return model.Record{Key: key, Value: []byte(value), Seq: seq}
+}
+
+func tomb(key string, seq uint64) model.Record {
+	return model.Record{Key: key, Tombstone: true, Seq: seq}
+}
+
+// sources vraca noviji izvor (kao memtable) i stariji (kao SSTable).
+func sources() []Source {
+	newer := []model.Record{
+		rec("b", "b2", 6),
+		tomb("c", 7),
+		rec("d", "d2", 8), rec("d", "d1", 4),
+		{Key: "e", Value: []byte("e2"), Seq: 9, ExpiresAt: 1},
+	}
+	older := []model.Record{
+		rec("a", "a1", 1),
+		rec("b", "b1", 2),
+		rec("c", "c1", 3),
+		rec("e", "e1", 5),
+		rec("f", "f1", 10),
+	}
+	return []Source{NewSliceSource(newer), NewSliceSource(older)}
+}
+
+// walk ide od First do kraja i vraca "kljuc=vrednost".
+func walk(it *Iterator) string {
+	var out []string
+	for ok := it.First(); ok; ok = it.Next() {
+		out = append(out, it.Key()+"="+string(it.Value()))
+	}
+	return fmt.Sprint(out)
+}
+
+func TestMergeShadowsOlderVersions(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name   string
+		maxSeq uint64
+		want   string
+	}{
+		// c je obrisan, e istekao
+		{"latest", ^uint64(0), "[a=a1 b=b2 d=d2 f=f1]"},
+		{"as of seq 5", 5, "[a=a1 b=b1 c=c1 d=d1 e=e1]"},
+		{"as of seq 2", 2, "[a=a1 b=b1]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			it := New(sources(), "", "", tt.maxSeq, now)
+			defer it.Close()
+			if got := walk(it); got != tt.want {
+				t.Fatalf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBoundsAndDirectionChanges(t *testing.T) {
+	it := New(sources(), "b", "f", ^uint64(0), time.Now())
+	defer it.Close()
+
+	expect := func(step string, ok bool, want string) {
+		t.Helper()
+		if want == "" {
+			if ok || it.Valid() {
+				t.Fatalf("%s: at %q, want end", step, it.Key())
+			}
+			return
+		}
+		if !ok || it.Key() != want {
+			t.Fatalf("%s: ok=%v key=%q, want %q", step, ok, it.Key(), want)
+		}
+	}
+
+	expect("First", it.First(), "b")
+	expect("Prev at lower bound", it.Prev(), "")
+	expect("Last", it.Last(), "d")
+	expect("Next at upper bound", it.Next(), "")
+	expect("Seek below lower bound", it.Seek("a"), "b")
+	expect("Seek deleted key", it.Seek("c"), "d")
+	expect("Seek upper bound", it.Seek("f"), "")
+
+	// promena smera ne preskace i ne ponavlja kljuceve
+	expect("First", it.First(), "b")
+	expect("Next", it.Next(), "d")
+	expect("Prev", it.Prev(), "b")
+	expect("Next", it.Next(), "d")
+	expect("Prev", it.Prev(), "b")
+	expect("Prev", it.Prev(), "")
+}
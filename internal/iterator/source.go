@@ -0,0 +1,63 @@
+package iterator
+
+import (
+	"sort"
+
+	"kv-engine/internal/model"
+)
+
+// Source je jedan sortiran izvor zapisa (memtable, SSTable ili ceo nivo):
+// kljucevi rastuce, a vise verzija istog kljuca od novije ka starijoj.
+// Posle svakog pomeranja Valid kaze da li je izvor na nekom zapisu; greska
+// citanja ga ostavlja nevalidnim i vraca se iz Err.
+type Source interface {
+	First()
+	Last()
+	SeekGE(key string) // prvi zapis sa kljucem >= key
+	SeekLT(key string) // poslednji zapis sa kljucem < key
+	Next()
+	Prev()
+
+	Valid() bool
+	Record() model.Record
+	Err() error
+
+	// Close oslobadja ono sto izvor drzi (npr. reference na tabele).
+	Close() error
+}
+
+// sliceSource je izvor nad vec sortiranim zapisima u memoriji.
+type sliceSource struct {
+	recs []model.Record
+	i    int
+}
+
+// NewSliceSource pravi izvor od zapisa sortiranih po kljucu (npr. Sorted()
+// jedne memtable tabele). Zapisi se ne kopiraju.
+func NewSliceSource(recs []model.Record) Source {
+	return &sliceSource{recs: recs, i: -1}
+}
+
+func (s *sliceSource) First() { s.i = 0 }
+
+func (s *sliceSource) Last() { s.i = len(s.recs) - 1 }
+
+func (s *sliceSource) SeekGE(key string) {
+	s.i = sort.Search(len(s.recs), func(i int) bool { return s.recs[i].Key >= key })
+}
+
+func (s *sliceSource) SeekLT(key string) {
+	s.i = sort.Search(len(s.recs), func(i int) bool { return s.recs[i].Key >= key }) - 1
+}
+
+func (s *sliceSource) Next() { s.i++ }
+
+func (s *sliceSource) Prev() { s.i-- }
+
+func (s *sliceSource) Valid() bool { return s.i >= 0 && s.i < len(s.recs) }
+
+func (s *sliceSource) Record() model.Record { return s.recs[s.i] }
+
+func (s *sliceSource) Err() error { return nil }
+
+func (s *sliceSource) Close() error { return nil }
@@ -7,7 +7,7 @@ import (
 type btreeNode struct {
 	leaf     bool
 	keys     []string
-	records  []model.Record
+	records  []versions
 	children []*btreeNode
 }
 
@@ -32,12 +32,12 @@ func NewBTreeMemtable(maxEntries int, maxBytes int64, t int) Memtable {
 	}
 }
 
-func (m *BTreeMemtable) Put(r model.Record) {
-	// overwrite ako postoji
+func (m *BTreeMemtable) Put(r model.Record, snaps []uint64) {
+	// nova verzija postojeceg kljuca
 	if old, ok := m.getRecord(r.Key); ok {
-		m.currentBytes -= estimateRecordSize(&old)
-		m.setRecord(r.Key, r)
-		m.currentBytes += estimateRecordSize(&r)
+		v := old.add(r, snaps)
+		m.currentBytes += v.size() - old.size()
+		m.setRecord(r.Key, v)
 		return
 	}
 
@@ -49,43 +49,33 @@ func (m *BTreeMemtable) Put(r model.Record) {
 		m.splitChild(newRoot, 0)
 		m.root = newRoot
 	}
-	m.insertNonFull(m.root, r.Key, r)
+	m.insertNonFull(m.root, r.Key, versions{r})
 
 	m.entriesNum++
 	m.currentBytes += estimateRecordSize(&r)
 }
 
-func (m *BTreeMemtable) Get(key string) model.GetResult {
-	rec, ok := m.getRecord(key)
+func (m *BTreeMemtable) Get(key string, maxSeq uint64) model.GetResult {
+	v, ok := m.getRecord(key)
 	if !ok {
 		return model.GetResult{Found: false}
 	}
-	return model.GetResult{
-		Value:     rec.Value,
-		Found:     true,
-		Tombstone: rec.Tombstone,
-		Seq:       rec.Seq,
-	}
+	return v.get(maxSeq)
 }
 
-func (m *BTreeMemtable) Delete(r model.Record) {
+func (m *BTreeMemtable) Delete(r model.Record, snaps []uint64) {
 	r.Tombstone = true
 	r.Value = nil
-	m.Put(r)
+	m.Put(r, snaps)
 }
 
 func (m *BTreeMemtable) IsFull() bool {
 	return m.entriesNum >= m.maxEntries || m.currentBytes >= m.maxBytes
 }
 
-func (m *BTreeMemtable) DrainSorted() []model.Record {
+func (m *BTreeMemtable) Sorted() []model.Record {
 	out := make([]model.Record, 0, m.entriesNum)
 	m.inOrder(m.root, &out)
-
-	// reset
-	m.root = &btreeNode{leaf: true}
-	m.entriesNum = 0
-	m.currentBytes = 0
 	return out
 }
 
@@ -97,18 +87,18 @@ func (m *BTreeMemtable) inOrder(n *btreeNode, out *[]model.Record) {
 	}
 	if n.leaf {
 		for i := 0; i < len(n.keys); i++ {
-			*out = append(*out, n.records[i])
+			*out = append(*out, n.records[i]...)
 		}
 		return
 	}
 	for i := 0; i < len(n.keys); i++ {
 		m.inOrder(n.children[i], out)
-		*out = append(*out, n.records[i])
+		*out = append(*out, n.records[i]...)
 	}
 	m.inOrder(n.children[len(n.children)-1], out)
 }
 
-func (m *BTreeMemtable) getRecord(key string) (model.Record, bool) {
+func (m *BTreeMemtable) getRecord(key string) (versions, bool) {
 	n := m.root
 	for {
 		i := lowerBound(n.keys, key)
@@ -116,13 +106,13 @@ func (m *BTreeMemtable) getRecord(key string) (model.Record, bool) {
 			return n.records[i], true
 		}
 		if n.leaf {
-			return model.Record{}, false
+			return nil, false
 		}
 		n = n.children[i]
 	}
 }
 
-func (m *BTreeMemtable) setRecord(key string, r model.Record) {
+func (m *BTreeMemtable) setRecord(key string, r versions) {
 	// pretpostavka: key postoji
 	n := m.root
 	for {
@@ -135,13 +125,13 @@ func (m *BTreeMemtable) setRecord(key string, r model.Record) {
 	}
 }
 
-func (m *BTreeMemtable) insertNonFull(x *btreeNode, key string, rec model.Record) {
+func (m *BTreeMemtable) insertNonFull(x *btreeNode, key string, rec versions) {
 
 	if x.leaf {
 		// ubaci u sortiran niz keys/records
 		pos := lowerBound(x.keys, key)
 		x.keys = append(x.keys, "")
-		x.records = append(x.records, model.Record{})
+		x.records = append(x.records, nil)
 		copy(x.keys[pos+1:], x.keys[pos:])
 		copy(x.records[pos+1:], x.records[pos:])
 		x.keys[pos] = key
@@ -189,7 +179,7 @@ func (m *BTreeMemtable) splitChild(x *btreeNode, i int) {
 
 	// ubaci novi key/record u x na poziciju i
 	x.keys = append(x.keys, "")
-	x.records = append(x.records, model.Record{})
+	x.records = append(x.records, nil)
 	copy(x.keys[i+1:], x.keys[i:])
 	copy(x.records[i+1:], x.records[i:])
 	x.keys[i] = midKey
@@ -9,7 +9,7 @@ import (
 type HashMapMemtable struct {
 	maxEntries   int
 	entriesNum   int
-	data         map[string]model.Record // čuvamo poslednji record po key
+	data         map[string]versions // verzije po key, najnovija prva
 	maxBytes     int64
 	currentBytes int64
 }
@@ -18,57 +18,42 @@ func NewHashMapMemtable(maxEntries int, maxBytes int64) Memtable {
 	return &HashMapMemtable{
 		maxEntries:   maxEntries,
 		maxBytes:     maxBytes,
-		data:         make(map[string]model.Record),
+		data:         make(map[string]versions),
 		entriesNum:   0,
 		currentBytes: 0,
 	}
 }
 
-func (m *HashMapMemtable) Put(r model.Record) {
-	if old, exists := m.data[r.Key]; exists {
-		m.currentBytes -= estimateRecordSize(&old)
-	} else {
+func (m *HashMapMemtable) Put(r model.Record, snaps []uint64) {
+	old, exists := m.data[r.Key]
+	if !exists {
 		m.entriesNum++
 	}
-	m.data[r.Key] = r
-	m.currentBytes += estimateRecordSize(&r)
+	v := old.add(r, snaps)
+	m.data[r.Key] = v
+	m.currentBytes += v.size() - old.size()
 }
 
-func (m *HashMapMemtable) Get(key string) model.GetResult {
-	rec, ok := m.data[key]
+func (m *HashMapMemtable) Get(key string, maxSeq uint64) model.GetResult {
+	v, ok := m.data[key]
 	if !ok {
 		return model.GetResult{Found: false}
 	}
-
-	return model.GetResult{
-		Key:       rec.Key,
-		Value:     rec.Value,
-		Found:     true,
-		Tombstone: rec.Tombstone,
-		Seq:       rec.Seq,
-	}
+	return v.get(maxSeq)
 }
 
-func (m *HashMapMemtable) Delete(r model.Record) {
+func (m *HashMapMemtable) Delete(r model.Record, snaps []uint64) {
 	r.Tombstone = true
 	r.Value = nil
-
-	if old, exists := m.data[r.Key]; exists {
-		m.currentBytes -= estimateRecordSize(&old)
-	} else {
-		m.entriesNum++
-	}
-
-	m.data[r.Key] = r
-	m.currentBytes += estimateRecordSize(&r)
+	m.Put(r, snaps)
 }
 
 func (m *HashMapMemtable) IsFull() bool {
 	return m.entriesNum >= m.maxEntries || m.currentBytes >= m.maxBytes
 }
 
-// DrainSorted: vrati sve zapise sortirane po ključu i isprazni memtable
-func (m *HashMapMemtable) DrainSorted() []model.Record {
+// Sorted: vrati sve zapise sortirane po ključu (verzije od najnovije)
+func (m *HashMapMemtable) Sorted() []model.Record {
 	keys := make([]string, 0, len(m.data))
 	for k := range m.data {
 		keys = append(keys, k)
@@ -77,13 +62,8 @@ func (m *HashMapMemtable) DrainSorted() []model.Record {
 
 	out := make([]model.Record, 0, len(keys))
 	for _, k := range keys {
-		out = append(out, m.data[k])
+		out = append(out, m.data[k]...)
 	}
-
-	// reset
-	m.data = make(map[string]model.Record)
-	m.entriesNum = 0
-	m.currentBytes = 0
 	return out
 }
 
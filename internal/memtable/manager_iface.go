@@ -3,8 +3,13 @@ package memtable
 import "kv-engine/internal/model"
 
 type MemtableManagerIface interface {
-	Get(key string) model.GetResult
-	Put(r model.Record) (flushNeeded bool, err error)
-	Delete(r model.Record) (flushNeeded bool, err error)
+	Get(key string, maxSeq uint64) model.GetResult
+	SortedTables() [][]model.Record
+	Put(r model.Record, snaps []uint64) (flushNeeded bool, err error)
+	Delete(r model.Record, snaps []uint64) (flushNeeded bool, err error)
+	Apply(recs []model.Record, snaps []uint64) (flushNeeded bool, err error)
 	NextFlushBatch() ([]model.Record, bool)
+	FlushDone()
+	Stalled() bool
+	FreezeActive() error
 }
@@ -2,6 +2,7 @@ package memtable
 
 import (
 	"fmt"
+	"sync"
 
 	"kv-engine/internal/model"
 )
@@ -10,7 +11,10 @@ type Factory func() Memtable
 
 // Drzi N memtable instanci: 1 RW (active) + do N-1 RO u roQueue (FIFO).
 // Flush je potreban kada popunimo svih N tabela (nema slobodnog slota).
+// Bezbedan je za konkurentno koriscenje: Get-ovi se ne blokiraju medjusobno.
 type MemtableManager struct {
+	mu sync.RWMutex
+
 	tables       []Memtable
 	used         []bool
 	activeFrozen bool
@@ -47,17 +51,21 @@ func NewMemtableManager(n int, factory Factory) (MemtableManagerIface, error) {
 	return m, nil
 }
 
-// Get: prvo active, pa RO od najnovijeg ka najstarijem (da uvek vratis najnoviju verziju kljuca).
-func (m *MemtableManager) Get(key string) model.GetResult {
+// Get: prvo active, pa RO od najnovijeg ka najstarijem (da uvek vratis najnoviju verziju kljuca
+// sa seq <= maxSeq).
+func (m *MemtableManager) Get(key string, maxSeq uint64) model.GetResult {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
 	// 1) active (najnovije)
-	if res := m.tables[m.active].Get(key); res.Found {
+	if res := m.tables[m.active].Get(key, maxSeq); res.Found {
 		return res
 	}
 
 	// 2) RO tabele od najnovije ka najstarijoj
 	for i := len(m.roQueue) - 1; i >= 0; i-- {
 		idx := m.roQueue[i]
-		if res := m.tables[idx].Get(key); res.Found {
+		if res := m.tables[idx].Get(key, maxSeq); res.Found {
 			return res
 		}
 	}
@@ -65,27 +73,83 @@ func (m *MemtableManager) Get(key string) model.GetResult {
 	return model.GetResult{Found: false}
 }
 
-// Put/Delete vracaju flushNeeded=true kad je active postala puna i nema slobodnog slota (tj. popunili smo svih N).
-func (m *MemtableManager) Put(r model.Record) (bool, error) {
-	m.tables[m.active].Put(r)
+// SortedTables vraca zapise svih tabela, svaku sortiranu po kljucu: prvo
+// active, pa RO od najnovije ka najstarijoj (isti redosled kao Get).
+// Tabele ostaju netaknute; za iteratore.
+func (m *MemtableManager) SortedTables() [][]model.Record {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	out := [][]model.Record{m.tables[m.active].Sorted()}
+	for i := len(m.roQueue) - 1; i >= 0; i-- {
+		idx := m.roQueue[i]
+		if idx == m.active {
+			continue
+		}
+		out = append(out, m.tables[idx].Sorted())
+	}
+	return out
+}
+
+// Put/Delete vracaju flushNeeded=true kad je active postala puna i presla u RO queue,
+// tj. kad postoji nova tabela koja ceka flush. snaps su seq-ovi otvorenih snapshot-a
+// (rastuce), da se ne izgube starije verzije koje oni jos vide.
+func (m *MemtableManager) Put(r model.Record, snaps []uint64) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.tables[m.active].Put(r, snaps)
+	return m.rotateIfNeeded()
+}
+
+func (m *MemtableManager) Delete(r model.Record, snaps []uint64) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.tables[m.active].Delete(r, snaps)
 	return m.rotateIfNeeded()
 }
 
-func (m *MemtableManager) Delete(r model.Record) (bool, error) {
-	m.tables[m.active].Delete(r)
+// Apply upisuje sve zapise (Put ili Delete, po Tombstone-u) u active tabelu
+// pod jednim lock-om, pa ih Get i SortedTables vide ili sve ili nijedan.
+// Tabela moze da predje svoju granicu; rotira se tek posle poslednjeg zapisa.
+func (m *MemtableManager) Apply(recs []model.Record, snaps []uint64) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, r := range recs {
+		if r.Tombstone {
+			m.tables[m.active].Delete(r, snaps)
+		} else {
+			m.tables[m.active].Put(r, snaps)
+		}
+	}
 	return m.rotateIfNeeded()
 }
 
 // rotateIfNeeded:
 // - ako active nije puna -> (false,nil)
-// - ako jeste -> prebaci active u RO queue
-//   - ako ima slobodan slot -> napravi novi RW u tom slotu, vrati (false,nil)
-//   - ako nema slobodnog slota -> vrati (true,nil) => flush needed
+// - ako jeste -> prebaci active u RO queue i vrati (true,nil) => flush needed
+//   - ako ima slobodan slot -> napravi novi RW u tom slotu
+//   - ako nema slobodnog slota -> active ostaje zamrznuta dok flush ne oslobodi slot
 func (m *MemtableManager) rotateIfNeeded() (bool, error) {
 	if !m.tables[m.active].IsFull() {
 		return false, nil
 	}
 
+	return true, m.freezeActive()
+}
+
+// FreezeActive prebacuje active u RO queue i kada nije puna (npr. pri gasenju,
+// da bi flush pokupio i nju).
+func (m *MemtableManager) FreezeActive() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.freezeActive()
+}
+
+func (m *MemtableManager) freezeActive() error {
 	// freeze active -> RO
 	if !m.activeFrozen {
 		m.roQueue = append(m.roQueue, m.active)
@@ -95,20 +159,27 @@ func (m *MemtableManager) rotateIfNeeded() (bool, error) {
 	// nadji slobodan slot za novi RW
 	free := m.findFreeSlot()
 	if free == -1 {
-		// svih N su zauzete => flush needed
-		return true, nil
+		// svih N su zauzete => upisi moraju da sacekaju flush
+		return nil
 	}
 
 	// kreiraj novi RW
 	m.tables[free] = m.factory()
 	if m.tables[free] == nil {
-		return false, fmt.Errorf("factory returned nil memtable")
+		return fmt.Errorf("factory returned nil memtable")
 	}
 	m.used[free] = true
 	m.activeFrozen = false
 	m.active = free
 
-	return false, nil
+	return nil
+}
+
+// Stalled je true kada su svi slotovi RO i cekaju flush, pa nema gde da se upisuje.
+func (m *MemtableManager) Stalled() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.activeFrozen
 }
 
 func (m *MemtableManager) findFreeSlot() int {
@@ -120,17 +191,31 @@ func (m *MemtableManager) findFreeSlot() int {
 	return -1
 }
 
+// NextFlushBatch vraca zapise najstarije RO tabele. Tabela ostaje u roQueue
+// (i vidljiva za Get) sve dok se posle uspesnog flush-a ne pozove FlushDone.
 func (m *MemtableManager) NextFlushBatch() ([]model.Record, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
 	if len(m.roQueue) == 0 {
 		return nil, false
 	}
+	return m.tables[m.roQueue[0]].Sorted(), true
+}
+
+// FlushDone izbacuje najstariju RO tabelu i oslobadja njen slot.
+func (m *MemtableManager) FlushDone() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.roQueue) == 0 {
+		return
+	}
 
 	idx := m.roQueue[0]
 	copy(m.roQueue[0:], m.roQueue[1:])
 	m.roQueue = m.roQueue[:len(m.roQueue)-1]
 
-	recs := m.tables[idx].DrainSorted()
-
 	// oslobodi slot
 	m.tables[idx] = nil
 	m.used[idx] = false
@@ -143,8 +228,6 @@ func (m *MemtableManager) NextFlushBatch() ([]model.Record, bool) {
 		m.active = idx
 		m.activeFrozen = false
 	}
-
-	return recs, true
 }
 
 var _ MemtableManagerIface = (*MemtableManager)(nil)
@@ -0,0 +1,66 @@
+package memtable
+
+import (
+	"fmt"
+	"testing"
+
+	"kv-engine/internal/model"
+)
+
+func memtables() map[string]func() Memtable {
+	return map[string]func() Memtable{
+		"hashmap":  func() Memtable { return NewHashMapMemtable(100, 1<<20) },
+		"btree":    func() Memtable { return NewBTreeMemtable(100, 1<<20, 2) },
+		"skiplist": func() Memtable { return NewSkipListMemtable(100, 1<<20) },
+	}
+}
+
+func put(key, value string, seq uint64) model.Record {
+	return model.Record{Key: key, Value: []byte(value), Seq: seq}
+}
+
+// sorted vraca Sorted() kao "kljuc@seq".
+func sorted(m Memtable) string {
+	var out []string
+	for _, r := range m.Sorted() {
+		out = append(out, fmt.Sprintf("%s@%d", r.Key, r.Seq))
+	}
+	return fmt.Sprint(out)
+}
+
+func TestVersionsForSnapshots(t *testing.T) {
+	for name, newTable := range memtables() {
+		t.Run(name, func(t *testing.T) {
+			m := newTable()
+			m.Put(put("a", "1", 1), nil)
+			m.Put(put("b", "1", 2), nil)
+			// snapshot na 2 vidi a@1
+			m.Put(put("a", "2", 3), []uint64{2})
+			m.Delete(model.Record{Key: "b", Tombstone: true, Seq: 4}, []uint64{2})
+			// a@3 ne vidi nijedan snapshot, a@1 i dalje vidi
+			m.Put(put("a", "3", 5), []uint64{2})
+
+			if got := sorted(m); got != "[a@5 a@1 b@4 b@2]" {
+				t.Fatalf("Sorted = %s", got)
+			}
+			if r := m.Get("a", 2); !r.Found || string(r.Value) != "1" {
+				t.Fatalf("Get(a, 2) = %+v", r)
+			}
+			if r := m.Get("a", 4); !r.Found || string(r.Value) != "1" {
+				t.Fatalf("Get(a, 4) = %+v, want the version the snapshot kept", r)
+			}
+			if r := m.Get("b", 10); !r.Found || !r.Tombstone {
+				t.Fatalf("Get(b, 10) = %+v, want tombstone", r)
+			}
+			if r := m.Get("a", 0); r.Found {
+				t.Fatalf("Get(a, 0) = %+v, want not found", r)
+			}
+
+			// bez snapshot-a ostaje samo najnovija verzija
+			m.Put(put("a", "4", 6), nil)
+			if got := sorted(m); got != "[a@6 b@4 b@2]" {
+				t.Fatalf("Sorted after snapshot closed = %s", got)
+			}
+		})
+	}
+}
@@ -14,7 +14,7 @@ const (
 
 type skipNode struct {
 	key     string
-	rec     model.Record
+	recs    versions
 	forward []*skipNode
 }
 
@@ -49,7 +49,7 @@ func NewSkipListMemtable(maxEntries int, maxBytes int64) Memtable {
 	}
 }
 
-func (m *SkipListMemtable) Put(r model.Record) {
+func (m *SkipListMemtable) Put(r model.Record, snaps []uint64) {
 	// update[] za svaku visinu
 	update := make([]*skipNode, m.maxLevel)
 	x := m.head
@@ -63,10 +63,10 @@ func (m *SkipListMemtable) Put(r model.Record) {
 
 	x = x.forward[0]
 	if x != nil && x.key == r.Key {
-		// overwrite postojeceg kljuca
-		m.currentBytes -= estimateRecordSize(&x.rec)
-		x.rec = r
-		m.currentBytes += estimateRecordSize(&x.rec)
+		// nova verzija postojeceg kljuca
+		v := x.recs.add(r, snaps)
+		m.currentBytes += v.size() - x.recs.size()
+		x.recs = v
 		return
 	}
 
@@ -81,7 +81,7 @@ func (m *SkipListMemtable) Put(r model.Record) {
 
 	newNode := &skipNode{
 		key:     r.Key,
-		rec:     r,
+		recs:    versions{r},
 		forward: make([]*skipNode, lvl),
 	}
 
@@ -91,10 +91,10 @@ func (m *SkipListMemtable) Put(r model.Record) {
 	}
 
 	m.entriesNum++
-	m.currentBytes += estimateRecordSize(&newNode.rec)
+	m.currentBytes += estimateRecordSize(&r)
 }
 
-func (m *SkipListMemtable) Get(key string) model.GetResult {
+func (m *SkipListMemtable) Get(key string, maxSeq uint64) model.GetResult {
 	x := m.head
 	for i := m.level - 1; i >= 0; i-- {
 		for x.forward[i] != nil && x.forward[i].key < key {
@@ -105,38 +105,25 @@ func (m *SkipListMemtable) Get(key string) model.GetResult {
 	if x == nil || x.key != key {
 		return model.GetResult{Found: false}
 	}
-	return model.GetResult{
-		Key:       x.key,
-		Value:     x.rec.Value,
-		Found:     true,
-		Tombstone: x.rec.Tombstone,
-		Seq:       x.rec.Seq,
-	}
+	return x.recs.get(maxSeq)
 }
 
-func (m *SkipListMemtable) Delete(r model.Record) {
+func (m *SkipListMemtable) Delete(r model.Record, snaps []uint64) {
 	r.Tombstone = true
 	r.Value = nil
-	m.Put(r) // isti key overwrite/insert, samo sa tombstone
+	m.Put(r, snaps) // nova verzija kljuca, samo sa tombstone
 }
 
 func (m *SkipListMemtable) IsFull() bool {
 	return m.entriesNum >= m.maxEntries || m.currentBytes >= m.maxBytes
 }
 
-func (m *SkipListMemtable) DrainSorted() []model.Record {
+func (m *SkipListMemtable) Sorted() []model.Record {
 	out := make([]model.Record, 0, m.entriesNum)
 
 	for x := m.head.forward[0]; x != nil; x = x.forward[0] {
-		out = append(out, x.rec)
+		out = append(out, x.recs...)
 	}
-
-	// reset
-	m.head = &skipNode{forward: make([]*skipNode, m.maxLevel)}
-	m.level = 1
-	m.entriesNum = 0
-	m.currentBytes = 0
-
 	return out
 }
 
@@ -3,12 +3,17 @@ package memtable
 import "kv-engine/internal/model"
 
 type Memtable interface {
-	Put(r model.Record)
-	Delete(r model.Record)
-	Get(key string) model.GetResult
+	// Put i Delete dodaju novu verziju kljuca; starije verzije ostaju samo
+	// ako ih vidi neki od snapshot-a iz snaps (seq-ovi, rastuce)
+	Put(r model.Record, snaps []uint64)
+	Delete(r model.Record, snaps []uint64)
 
-	// za flush:
-	DrainSorted() []model.Record
+	// najnovija verzija kljuca sa seq <= maxSeq
+	Get(key string, maxSeq uint64) model.GetResult
+
+	// za flush: svi zapisi sortirani po kljucu (verzije istog kljuca od
+	// novije ka starijoj), tabela ostaje netaknuta
+	Sorted() []model.Record
 
 	// za kontrolu punjenja:
 	IsFull() bool
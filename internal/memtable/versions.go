@@ -0,0 +1,51 @@
+package memtable
+
+import "kv-engine/internal/model"
+
+// versions su verzije jednog kljuca u tabeli, od najnovije ka najstarijoj.
+// Starija verzija ostaje samo dok je vidi neki otvoren snapshot; bez
+// snapshot-a kljuc ima samo jednu verziju.
+type versions []model.Record
+
+// add vraca lanac sa r kao najnovijom verzijom, bez starijih verzija koje ne
+// vidi nijedan snapshot iz snaps (seq-ovi, rastuce).
+func (v versions) add(r model.Record, snaps []uint64) versions {
+	if len(snaps) == 0 {
+		return versions{r}
+	}
+	out := make(versions, 0, len(v)+1)
+	out = append(out, r)
+	newer := r.Seq
+	for _, old := range v {
+		if model.SnapshotSees(snaps, old.Seq, newer) {
+			out = append(out, old)
+		}
+		newer = old.Seq
+	}
+	return out
+}
+
+// get vraca najnoviju verziju sa seq <= maxSeq.
+func (v versions) get(maxSeq uint64) model.GetResult {
+	for _, r := range v {
+		if r.Seq <= maxSeq {
+			return model.GetResult{
+				Key:       r.Key,
+				Value:     r.Value,
+				Found:     true,
+				Tombstone: r.Tombstone,
+				Seq:       r.Seq,
+				ExpiresAt: r.ExpiresAt,
+			}
+		}
+	}
+	return model.GetResult{Found: false}
+}
+
+func (v versions) size() int64 {
+	var n int64
+	for i := range v {
+		n += estimateRecordSize(&v[i])
+	}
+	return n
+}
@@ -0,0 +1,109 @@
+package merkle
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+
+	"kv-engine/internal/model"
+)
+
+var ErrInvalidMerkleData = errors.New("invalid merkle tree data")
+
+// HashBlock je hash jednog lista (bloka podataka).
+func HashBlock(block []byte) [32]byte {
+	return sha256.Sum256(block)
+}
+
+// Build pravi stablo nad hash-evima listova od po leafSize bajtova.
+func Build(leaves [][32]byte, leafSize int) model.MerkleTree {
+	return model.MerkleTree{Root: Root(leaves), LeafHashes: leaves, LeafSize: uint32(leafSize)}
+}
+
+// Root racuna koren: parovi cvorova se spajaju sha256(levi || desni) dok ne
+// ostane jedan; neparan cvor na kraju nivoa se prenosi nivo vise bez izmene.
+func Root(leaves [][32]byte) [32]byte {
+	if len(leaves) == 0 {
+		return [32]byte{}
+	}
+
+	level := append([][32]byte(nil), leaves...)
+	for len(level) > 1 {
+		next := make([][32]byte, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+				continue
+			}
+			var pair [64]byte
+			copy(pair[:32], level[i][:])
+			copy(pair[32:], level[i+1][:])
+			next = append(next, sha256.Sum256(pair[:]))
+		}
+		level = next
+	}
+	return level[0]
+}
+
+// Diff vraca indekse listova koji se razlikuju izmedju sacuvanog i
+// izracunatog stabla, ukljucujuci listove koji postoje samo u jednom od njih.
+func Diff(stored, actual model.MerkleTree) []uint64 {
+	if stored.Root == actual.Root && len(stored.LeafHashes) == len(actual.LeafHashes) {
+		return nil
+	}
+
+	var out []uint64
+	n := max(len(stored.LeafHashes), len(actual.LeafHashes))
+	for i := 0; i < n; i++ {
+		if i >= len(stored.LeafHashes) || i >= len(actual.LeafHashes) ||
+			stored.LeafHashes[i] != actual.LeafHashes[i] {
+			out = append(out, uint64(i))
+		}
+	}
+	return out
+}
+
+// Serialize format:
+// [0:32]  -> root
+// [32:36] -> broj listova
+// [36:]   -> hash-evi listova, po 32 bajta
+// [kraj]  -> velicina lista u32
+//
+// Stariji format nema velicinu lista; razlikuje se po duzini.
+func Serialize(t model.MerkleTree) []byte {
+	buf := make([]byte, 36+32*len(t.LeafHashes)+4)
+	copy(buf[0:32], t.Root[:])
+	binary.BigEndian.PutUint32(buf[32:36], uint32(len(t.LeafHashes)))
+	for i, h := range t.LeafHashes {
+		copy(buf[36+32*i:], h[:])
+	}
+	binary.BigEndian.PutUint32(buf[len(buf)-4:], t.LeafSize)
+	return buf
+}
+
+// Deserialize proverava i da koren odgovara listovima, pa je ostecen
+// merkle fajl greska, a ne lazno ostecen blok podataka.
+func Deserialize(data []byte) (model.MerkleTree, error) {
+	if len(data) < 36 {
+		return model.MerkleTree{}, ErrInvalidMerkleData
+	}
+	n := int(binary.BigEndian.Uint32(data[32:36]))
+	var t model.MerkleTree
+	switch len(data) {
+	case 36 + 32*n:
+	case 36 + 32*n + 4:
+		t.LeafSize = binary.BigEndian.Uint32(data[len(data)-4:])
+	default:
+		return model.MerkleTree{}, ErrInvalidMerkleData
+	}
+
+	copy(t.Root[:], data[0:32])
+	t.LeafHashes = make([][32]byte, n)
+	for i := range t.LeafHashes {
+		copy(t.LeafHashes[i][:], data[36+32*i:])
+	}
+	if Root(t.LeafHashes) != t.Root {
+		return model.MerkleTree{}, ErrInvalidMerkleData
+	}
+	return t, nil
+}
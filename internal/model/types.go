@@ -1,5 +1,10 @@
 package model
 
+import (
+	"sort"
+	"time"
+)
+
 type Record struct {
 	Key       string
 	Value     []byte
@@ -8,6 +13,11 @@ type Record struct {
 	ExpiresAt uint64
 }
 
+// Expired: zapis sa TTL-om (ExpiresAt != 0, Unix sekunde) je istekao u trenutku now.
+func (r Record) Expired(now time.Time) bool {
+	return expired(r.ExpiresAt, now)
+}
+
 type GetResult struct {
 	Key       string
 	Value     []byte
@@ -17,6 +27,22 @@ type GetResult struct {
 	ExpiresAt uint64
 }
 
+func (r GetResult) Expired(now time.Time) bool {
+	return expired(r.ExpiresAt, now)
+}
+
+func expired(expiresAt uint64, now time.Time) bool {
+	return expiresAt != 0 && uint64(now.Unix()) >= expiresAt
+}
+
+// SnapshotSees kaze da li neki snapshot iz snaps (seq-ovi, rastuce) vidi
+// verziju kljuca sa seq-om seq, kada je sledeca novija verzija newerSeq:
+// snapshot S je vidi ako je seq <= S < newerSeq.
+func SnapshotSees(snaps []uint64, seq, newerSeq uint64) bool {
+	i := sort.Search(len(snaps), func(i int) bool { return snaps[i] >= seq })
+	return i < len(snaps) && snaps[i] < newerSeq
+}
+
 type IndexEntry struct {
 	Key        string
 	DataOffset uint64
@@ -37,9 +63,14 @@ type BloomFilter struct {
 type MerkleTree struct {
 	Root       [32]byte
 	LeafHashes [][32]byte
+	LeafSize   uint32 // bajtova po listu; 0 = nepoznato (stariji format)
 }
 
+// SSTFooter opisuje gde su sekcije SSTable-a: stoji na kraju single-file
+// tabele, a u multi-file rezimu u zasebnom .meta fajlu. Data sekcija uvek
+// pocinje od nule.
 type SSTFooter struct {
+	DataLen       uint64
 	IndexOffset   uint64
 	IndexLen      uint64
 	SummaryOffset uint64
@@ -48,4 +79,6 @@ type SSTFooter struct {
 	FilterLen     uint64
 	MerkleOffset  uint64
 	MerkleLen     uint64
+	Version       uint32
+	Magic         uint64
 }
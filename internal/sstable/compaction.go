@@ -0,0 +1,322 @@
+package sstable
+
+import (
+	"errors"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"kv-engine/internal/config"
+	"kv-engine/internal/model"
+)
+
+// Kompakcija spaja vise tabela u nove. Koje tabele i kada, odlucuje strategija
+// (Compactor): leveled (leveled.go) ili size-tiered (sizetiered.go). Spajanje
+// i zamena tabela su isti za obe.
+//
+// Pri spajanju ostaje najnovija verzija kljuca i starije verzije koje jos
+// vidi neki otvoren snapshot. Tombstone i istekli zapisi se izbacuju tek kada
+// van kompakcije nema starijih verzija koje bi inace ponovo postale vidljive.
+
+// errCompactionStopped: kompakcija je prekinuta jer se store zatvara.
+var errCompactionStopped = errors.New("sstable: compaction stopped")
+
+// Compactor je strategija kompakcije: na osnovu trenutnog rasporeda tabela
+// bira sledecu kompakciju ili vraca nil kada nema sta da se radi.
+type Compactor interface {
+	Name() string
+
+	// pick se poziva pod m.mu, uvek iz iste gorutine; levels se ne sme menjati.
+	pick(levels [][]*table) *compaction
+}
+
+// newCompactor pravi strategiju zadatu u config-u.
+func newCompactor(cfg config.Config) Compactor {
+	if cfg.CompactionStrategy == "size_tiered" {
+		return &sizeTiered{
+			minThreshold: cfg.L0CompactionTrigger,
+		}
+	}
+	return &leveled{
+		l0Trigger:       cfg.L0CompactionTrigger,
+		levelBaseBytes:  cfg.LevelBaseMaxBytes,
+		levelMultiplier: cfg.LevelSizeMultiplier,
+		targetFileBytes: cfg.SSTableTargetFileBytes,
+	}
+}
+
+// CompactionStats su brojaci rada kompakcije, da bi strategije mogle da se
+// porede na istim podacima.
+type CompactionStats struct {
+	Strategy     string
+	Compactions  uint64 // zavrsene kompakcije
+	TablesIn     uint64 // spojene tabele
+	TablesOut    uint64 // nove tabele
+	BytesWritten uint64 // ukupna velicina data delova novih tabela
+}
+
+type compactionCounters struct {
+	compactions  atomic.Uint64
+	tablesIn     atomic.Uint64
+	tablesOut    atomic.Uint64
+	bytesWritten atomic.Uint64
+}
+
+// CompactionStats vraca trenutne brojace kompakcije.
+func (m *Manager) CompactionStats() CompactionStats {
+	return CompactionStats{
+		Strategy:     m.compactor.Name(),
+		Compactions:  m.compactStats.compactions.Load(),
+		TablesIn:     m.compactStats.tablesIn.Load(),
+		TablesOut:    m.compactStats.tablesOut.Load(),
+		BytesWritten: m.compactStats.bytesWritten.Load(),
+	}
+}
+
+type compaction struct {
+	level    int      // nivo ulaznih tabela
+	outLevel int      // nivo na koji idu nove tabele (level ili level+1)
+	inputs   []*table // ulazne tabele (L0: uzastopne, od najstarije ka najnovijoj)
+	overlap  []*table // tabele sa izlaznog nivoa koje se preklapaju sa ulazom
+	bottom   bool     // van kompakcije nema starijih verzija kljuceva iz ulaza
+	snaps    []uint64 // seq-ovi snapshot-a otvorenih pri pocetku kompakcije, rastuce
+
+	// priblizna velicina izlaznih tabela; 0 = sve u jednu tabelu
+	splitBytes int64
+}
+
+// Compact radi kompakcije dok god strategija ima sta da spoji ili dok se
+// stop ne zatvori. snapshots vraca seq-ove otvorenih snapshot-a (rastuce);
+// snapshot otvoren kasnije vidi samo najnovije verzije, pa je dovoljno
+// pitati na pocetku svake kompakcije. Sme da je pokrece samo jedna gorutina
+// u isto vreme; flush-evi i citanja mogu da teku paralelno.
+func (m *Manager) Compact(stop <-chan struct{}, snapshots func() []uint64) error {
+	for {
+		select {
+		case <-stop:
+			return nil
+		default:
+		}
+
+		m.mu.RLock()
+		c := m.compactor.pick(m.levels)
+		m.mu.RUnlock()
+		if c == nil {
+			return nil
+		}
+		c.snaps = snapshots()
+
+		err := m.runCompaction(c, stop)
+		if err == errCompactionStopped {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// noOlderVersions proverava da nijedna tabela ispod nivoa level ne pokriva
+// opseg kljuceva tabela iz tables.
+func noOlderVersions(levels [][]*table, level int, tables []*table) bool {
+	minKey, maxKey := keyRange(tables)
+	for _, lt := range levels[level+1:] {
+		for _, t := range lt {
+			if t.overlaps(minKey, maxKey) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func keyRange(tables []*table) (string, string) {
+	minKey, maxKey := tables[0].minKey, tables[0].maxKey
+	for _, t := range tables[1:] {
+		minKey = min(minKey, t.minKey)
+		maxKey = max(maxKey, t.maxKey)
+	}
+	return minKey, maxKey
+}
+
+// runCompaction spaja ulazne tabele u nove tabele izlaznog nivoa, pa ih
+// jednom izmenom u manifest-u zamenjuje. Fajlovi starih tabela se brisu kada
+// ih zavrse citaoci koji su ih vec uzeli.
+func (m *Manager) runCompaction(c *compaction, stop <-chan struct{}) error {
+	outputs, err := m.mergeTables(c, stop)
+	if err != nil {
+		m.release(outputs)
+		return err
+	}
+
+	m.mu.Lock()
+	oldIn, oldOut := m.levels[c.level], m.levels[c.outLevel]
+	if c.outLevel == c.level {
+		// isti nivo (L0): nova tabela zauzima mesto spojenih, da L0 ostane
+		// poredjan po starosti podataka
+		m.levels[c.level] = replaceRun(oldIn, c.inputs, outputs)
+	} else {
+		m.levels[c.level] = without(oldIn, c.inputs)
+		next := append(without(oldOut, c.overlap), outputs...)
+		sort.Slice(next, func(i, j int) bool { return next[i].minKey < next[j].minKey })
+		m.levels[c.outLevel] = next
+	}
+	var edit []tableEdit
+	for _, t := range c.inputs {
+		edit = append(edit, deleteEdit(t))
+	}
+	for _, t := range c.overlap {
+		edit = append(edit, deleteEdit(t))
+	}
+	for _, t := range outputs {
+		edit = append(edit, addEdit(t))
+	}
+	if err := m.logEdit(edit...); err != nil {
+		m.levels[c.level], m.levels[c.outLevel] = oldIn, oldOut
+		m.mu.Unlock()
+		m.release(outputs)
+		return err
+	}
+	m.mu.Unlock()
+
+	m.compactStats.compactions.Add(1)
+	m.compactStats.tablesIn.Add(uint64(len(c.inputs) + len(c.overlap)))
+	m.compactStats.tablesOut.Add(uint64(len(outputs)))
+	for _, t := range outputs {
+		m.compactStats.bytesWritten.Add(uint64(t.size()))
+	}
+
+	m.release(c.inputs)
+	m.release(c.overlap)
+	return nil
+}
+
+// replaceRun menja uzastopne tabele run u tables tabelama out.
+func replaceRun(tables, run, out []*table) []*table {
+	i := 0
+	for i < len(tables) && tables[i] != run[0] {
+		i++
+	}
+	next := make([]*table, 0, len(tables)-len(run)+len(out))
+	next = append(next, tables[:i]...)
+	next = append(next, out...)
+	return append(next, tables[i+len(run):]...)
+}
+
+// without vraca tabele iz tables koje nisu u remove, u istom redosledu.
+func without(tables, remove []*table) []*table {
+	skip := make(map[*table]bool, len(remove))
+	for _, t := range remove {
+		skip[t] = true
+	}
+	out := make([]*table, 0, len(tables))
+	for _, t := range tables {
+		if !skip[t] {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// mergeTables cita ulaz kompakcije kao jedan sortiran niz i pise ga u tabele
+// izlaznog nivoa od priblizno splitBytes (tabela se zavrsava tek posle svih
+// verzija jednog kljuca). Vraca i ono sto je stigao da upise kada vrati
+// gresku, da bi pozivalac to obrisao.
+func (m *Manager) mergeTables(c *compaction, stop <-chan struct{}) ([]*table, error) {
+	// prioritet izvora: noviji L0 pre starijeg, ulazni nivo pre izlaznog
+	var iters []*tableIter
+	defer func() {
+		for _, it := range iters {
+			it.close()
+		}
+	}()
+	for i := len(c.inputs) - 1; i >= 0; i-- {
+		it, err := openTableIter(c.inputs[i], len(iters))
+		if err != nil {
+			return nil, err
+		}
+		iters = append(iters, it)
+	}
+	for _, t := range c.overlap {
+		it, err := openTableIter(t, len(iters))
+		if err != nil {
+			return nil, err
+		}
+		iters = append(iters, it)
+	}
+
+	var outputs []*table
+	var batch []model.Record
+	var batchBytes int64
+	emit := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		t, err := m.writeTable(c.outLevel, batch)
+		if err != nil {
+			return err
+		}
+		outputs = append(outputs, t)
+		batch, batchBytes = nil, 0
+		return nil
+	}
+
+	now := time.Now()
+	// verzije tekuceg kljuca koje ostaju; idu u batch zajedno, da se verzije
+	// jednog kljuca ne podele izmedju dve tabele
+	var versions []model.Record
+	endKey := func() error {
+		if c.bottom {
+			// ispod nema starijih verzija, pa tombstone i istekli zapisi na
+			// kraju lanca vise nista ne zaklanjaju
+			for n := len(versions); n > 0 && (versions[n-1].Tombstone || versions[n-1].Expired(now)); n-- {
+				versions = versions[:n-1]
+			}
+		}
+		for _, rec := range versions {
+			batch = append(batch, rec)
+			batchBytes += int64(len(rec.Key) + len(rec.Value))
+		}
+		versions = versions[:0]
+		if c.splitBytes > 0 && batchBytes >= c.splitBytes {
+			return emit()
+		}
+		return nil
+	}
+
+	merged := newMergeIter(iters)
+	var key string
+	var newer uint64 // seq sledece novije verzije tekuceg kljuca
+	for n := 0; merged.next(); n++ {
+		if n%1024 == 0 {
+			select {
+			case <-stop:
+				return outputs, errCompactionStopped
+			default:
+			}
+		}
+
+		rec := merged.rec
+		if n == 0 || rec.Key != key {
+			if err := endKey(); err != nil {
+				return outputs, err
+			}
+			// najnovija verzija uvek ostaje
+			key = rec.Key
+			versions = append(versions, rec)
+		} else if model.SnapshotSees(c.snaps, rec.Seq, newer) {
+			versions = append(versions, rec)
+		}
+		newer = rec.Seq
+	}
+	if merged.err != nil {
+		return outputs, merged.err
+	}
+	if err := endKey(); err != nil {
+		return outputs, err
+	}
+	if err := emit(); err != nil {
+		return outputs, err
+	}
+	return outputs, nil
+}
@@ -0,0 +1,148 @@
+package sstable
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"kv-engine/internal/model"
+)
+
+func noSnapshots() []uint64 { return nil }
+
+func TestCompactionKeepsInputsOnCorruptRecord(t *testing.T) {
+	dir := t.TempDir()
+	m := openTestManager(t, dir)
+	defer m.Close()
+	flushTables(t, m, 4)
+
+	// keyLen prvog zapisa jedne tabele pokazuje iza kraja data dela
+	data := m.levels[0][1].data.path
+	b, _ := os.ReadFile(data)
+	b[0] = 100
+	os.WriteFile(data, b, 0644)
+
+	if err := m.Compact(make(chan struct{}), noSnapshots); err == nil {
+		t.Fatal("Compact succeeded over a corrupted table")
+	}
+	if n := len(m.levels[0]); n != 4 {
+		t.Fatalf("L0 tables = %d, want 4", n)
+	}
+	if n := len(m.levels[1]); n != 0 {
+		t.Fatalf("L1 tables = %d, want 0", n)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "level0", "*"+dataExt))
+	if len(files) != 4 {
+		t.Fatalf("L0 data files = %d, want 4", len(files))
+	}
+	for _, key := range []string{"k0", "k2", "k3"} {
+		mustGet(t, m, key)
+	}
+}
+
+// mergeOutput spaja L0 tabele kao kompakcija u L1 i vraca izlaz kao
+// "kljuc@seq" ("x" na kraju za tombstone), po tabelama.
+func mergeOutput(t *testing.T, m *Manager, bottom bool, snaps []uint64, splitBytes int64) [][]string {
+	t.Helper()
+	c := &compaction{
+		level:      0,
+		outLevel:   1,
+		inputs:     m.levels[0],
+		bottom:     bottom,
+		snaps:      snaps,
+		splitBytes: splitBytes,
+	}
+	outputs, err := m.mergeTables(c, make(chan struct{}))
+	defer m.release(outputs)
+	if err != nil {
+		t.Fatalf("mergeTables: %v", err)
+	}
+
+	var out [][]string
+	for _, tb := range outputs {
+		var recs []string
+		err := m.readRecords(tb, func(r model.Record) bool {
+			s := fmt.Sprintf("%s@%d", r.Key, r.Seq)
+			if r.Tombstone {
+				s += "x"
+			}
+			recs = append(recs, s)
+			return true
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, recs)
+	}
+	return out
+}
+
+func TestMergeTombstonesAndSnapshots(t *testing.T) {
+	m := openTestManager(t, t.TempDir())
+	defer m.Close()
+
+	older := []model.Record{
+		{Key: "a", Value: []byte("a1"), Seq: 1},
+		{Key: "b", Value: []byte("b1"), Seq: 2},
+		{Key: "c", Value: []byte("c1"), Seq: 3},
+		{Key: "d", Value: []byte("d1"), Seq: 4},
+	}
+	newer := []model.Record{
+		{Key: "a", Value: []byte("a2"), Seq: 5},
+		{Key: "b", Tombstone: true, Seq: 6},
+		{Key: "c", Value: []byte("c2"), Seq: 7},
+		{Key: "d", Value: []byte("d2"), Seq: 8, ExpiresAt: 1}, // davno istekao
+	}
+	if err := m.Flush(older); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Flush(newer); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		bottom bool
+		snaps  []uint64
+		want   string
+	}{
+		{"no snapshots", false, nil, "[[a@5 b@6x c@7 d@8]]"},
+		{"no snapshots, bottom", true, nil, "[[a@5 c@7]]"},
+		// snapshot na 4 vidi sve starije verzije, pa tombstone i istekli
+		// zapis nisu na kraju lanca i ostaju
+		{"snapshot before updates, bottom", true, []uint64{4}, "[[a@5 a@1 b@6x b@2 c@7 c@3 d@8 d@4]]"},
+		// snapshot na 6 vidi a@5, tombstone b-a, c@3 i d@4; istekli d@8
+		// ostaje da d@4 ne bi ponovo postao vidljiv
+		{"snapshot in the middle, bottom", true, []uint64{6}, "[[a@5 c@7 c@3 d@8 d@4]]"},
+		{"snapshot in the middle", false, []uint64{6}, "[[a@5 b@6x c@7 c@3 d@8 d@4]]"},
+		{"two snapshots, bottom", true, []uint64{2, 6}, "[[a@5 a@1 b@6x b@2 c@7 c@3 d@8 d@4]]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fmt.Sprint(mergeOutput(t, m, tt.bottom, tt.snaps, 0)); got != tt.want {
+				t.Fatalf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeSplitsAtKeyBoundaries(t *testing.T) {
+	m := openTestManager(t, t.TempDir())
+	defer m.Close()
+	for seq := uint64(1); seq <= 6; seq += 2 {
+		recs := []model.Record{
+			{Key: "a", Value: []byte("v"), Seq: seq},
+			{Key: "b", Value: []byte("v"), Seq: seq + 1},
+		}
+		if err := m.Flush(recs); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// svaki zapis prelazi splitBytes, ali verzije kljuca ostaju zajedno
+	got := fmt.Sprint(mergeOutput(t, m, true, []uint64{2, 4}, 1))
+	if want := "[[a@5 a@3 a@1] [b@6 b@4 b@2]]"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
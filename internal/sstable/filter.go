@@ -0,0 +1,63 @@
+package sstable
+
+import (
+	"sync/atomic"
+
+	"kv-engine/internal/probabilistic/blooms/bloom"
+)
+
+// FilterStats su brojaci rada bloom filtera pri Get-u.
+type FilterStats struct {
+	Hits           uint64 // filter je rekao "mozda" i kljuc je bio u tabeli
+	Misses         uint64 // filter je rekao "sigurno nije", tabela je preskocena
+	FalsePositives uint64 // filter je rekao "mozda", a kljuc nije bio u tabeli
+}
+
+type filterCounters struct {
+	hits           atomic.Uint64
+	misses         atomic.Uint64
+	falsePositives atomic.Uint64
+}
+
+// FilterStats vraca trenutne brojace bloom filtera.
+func (m *Manager) FilterStats() FilterStats {
+	return FilterStats{
+		Hits:           m.filterStats.hits.Load(),
+		Misses:         m.filterStats.misses.Load(),
+		FalsePositives: m.filterStats.falsePositives.Load(),
+	}
+}
+
+// loadFilter vraca bloom filter tabele iz cache-a ili ga cita sa diska.
+// Tabele bez filtera vracaju nil. Citanje sa diska ide van filterMu, da
+// Get-ovi nad drugim tabelama ne bi cekali na njega; ako isti filter u
+// medjuvremenu ucita neko drugi, vazi onaj koji je prvi upisan. Pozivalac
+// drzi referencu na t, pa unref ne brise unos dok traje citanje.
+func (m *Manager) loadFilter(t *table) (*bloom.BloomFilter, error) {
+	if !t.filter.exists() {
+		return nil, nil
+	}
+
+	m.filterMu.Lock()
+	bf, ok := m.filters[t.name]
+	m.filterMu.Unlock()
+	if ok {
+		return bf, nil
+	}
+
+	data, err := m.readAll(t.filter)
+	if err != nil {
+		return nil, err
+	}
+	if bf, err = bloom.Deserialize(data); err != nil {
+		return nil, err
+	}
+
+	m.filterMu.Lock()
+	defer m.filterMu.Unlock()
+	if cur, ok := m.filters[t.name]; ok {
+		return cur, nil
+	}
+	m.filters[t.name] = bf
+	return bf, nil
+}
@@ -0,0 +1,91 @@
+package sstable
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"kv-engine/internal/model"
+)
+
+// Footer SSTable-a, fiksne velicine:
+//
+//	[dataLen u64]
+//	[indexOffset u64][indexLen u64][summaryOffset u64][summaryLen u64]
+//	[filterOffset u64][filterLen u64][merkleOffset u64][merkleLen u64]
+//	[version u32][magic u64]
+//
+// Single-file tabela ga drzi na samom kraju poslednjeg bloka, a multi-file
+// tabela u .meta fajlu (tamo su svi offseti nula).
+const (
+	footerSize = 9*8 + 4 + 8
+
+	footerMagic   uint64 = 0x6b765f7373746162 // "kv_sstab"
+	formatVersion uint32 = 2                  // 2: zapisi cuvaju expiresAt
+)
+
+// errBadFooter: footer nije ispravan (npr. flush je prekinut pre kraja).
+var errBadFooter = errors.New("sstable: bad footer")
+
+func encodeFooter(f model.SSTFooter) []byte {
+	buf := make([]byte, footerSize)
+	for i, v := range []uint64{
+		f.DataLen,
+		f.IndexOffset, f.IndexLen,
+		f.SummaryOffset, f.SummaryLen,
+		f.FilterOffset, f.FilterLen,
+		f.MerkleOffset, f.MerkleLen,
+	} {
+		binary.BigEndian.PutUint64(buf[i*8:], v)
+	}
+	binary.BigEndian.PutUint32(buf[72:], f.Version)
+	binary.BigEndian.PutUint64(buf[76:], f.Magic)
+	return buf
+}
+
+// decodeFooter proverava magic i verziju; da li sekcije staju u fajl
+// proverava pozivalac.
+func decodeFooter(buf []byte) (model.SSTFooter, error) {
+	if len(buf) != footerSize {
+		return model.SSTFooter{}, errBadFooter
+	}
+
+	u := func(i int) uint64 { return binary.BigEndian.Uint64(buf[i*8:]) }
+	f := model.SSTFooter{
+		DataLen:       u(0),
+		IndexOffset:   u(1),
+		IndexLen:      u(2),
+		SummaryOffset: u(3),
+		SummaryLen:    u(4),
+		FilterOffset:  u(5),
+		FilterLen:     u(6),
+		MerkleOffset:  u(7),
+		MerkleLen:     u(8),
+		Version:       binary.BigEndian.Uint32(buf[72:]),
+		Magic:         binary.BigEndian.Uint64(buf[76:]),
+	}
+
+	if f.Magic != footerMagic {
+		return model.SSTFooter{}, errBadFooter
+	}
+	if f.Version == 0 || f.Version > formatVersion {
+		return model.SSTFooter{}, fmt.Errorf("sstable: unsupported format version %d", f.Version)
+	}
+	return f, nil
+}
+
+// fits proverava da sve sekcije iz footer-a staju u prvih limit bajtova fajla.
+func fits(f model.SSTFooter, limit uint64) bool {
+	for _, s := range [][2]uint64{
+		{0, f.DataLen},
+		{f.IndexOffset, f.IndexLen},
+		{f.SummaryOffset, f.SummaryLen},
+		{f.FilterOffset, f.FilterLen},
+		{f.MerkleOffset, f.MerkleLen},
+	} {
+		if s[0] > limit || s[1] > limit-s[0] {
+			return false
+		}
+	}
+	return true
+}
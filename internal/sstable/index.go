@@ -0,0 +1,64 @@
+package sstable
+
+import (
+	"encoding/binary"
+	"io"
+
+	"kv-engine/internal/model"
+)
+
+// [keyLen uvarint][key][dataOffset uvarint]
+
+func encodeIndexEntry(e model.IndexEntry) []byte {
+	tmp := make([]byte, 10)
+	buf := make([]byte, 0, 10+len(e.Key)+10)
+
+	n := binary.PutUvarint(tmp, uint64(len(e.Key)))
+	buf = append(buf, tmp[:n]...)
+	buf = append(buf, e.Key...)
+	n = binary.PutUvarint(tmp, e.DataOffset)
+	buf = append(buf, tmp[:n]...)
+
+	return buf
+}
+
+// decodeIndexEntry vraca io.EOF samo kada je reader tacno na kraju index-a.
+func decodeIndexEntry(r *sectionReader) (model.IndexEntry, error) {
+	keyLen, err := binary.ReadUvarint(r)
+	if err != nil {
+		return model.IndexEntry{}, err
+	}
+
+	kb, err := r.readN(keyLen)
+	if err != nil {
+		return model.IndexEntry{}, err
+	}
+
+	off, err := binary.ReadUvarint(r)
+	if err != nil {
+		return model.IndexEntry{}, unexpectedEOF(err)
+	}
+
+	return model.IndexEntry{Key: string(kb), DataOffset: off}, nil
+}
+
+// findInIndex prolazi kroz sortiran deo index-a i vraca offsete svih verzija
+// kljuca, od najnovije ka najstarijoj. r je deo index-a koji pokriva summary.
+func findInIndex(r *sectionReader, key string) ([]uint64, error) {
+	var offs []uint64
+	for {
+		e, err := decodeIndexEntry(r)
+		if err == io.EOF {
+			return offs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if e.Key == key {
+			offs = append(offs, e.DataOffset)
+		}
+		if e.Key > key {
+			return offs, nil
+		}
+	}
+}
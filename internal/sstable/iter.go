@@ -0,0 +1,264 @@
+package sstable
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sort"
+
+	"kv-engine/internal/iterator"
+	"kv-engine/internal/model"
+)
+
+// Sources vraca izvore za iterator nad svim tabelama koje mogu da sadrze
+// kljuceve iz [lower, upper) ("" = bez granice): svaku L0 tabelu posebno, od
+// najnovije, pa po jedan izvor za svaki dublji nivo. Izvori drze reference na
+// tabele dok se ne zatvore, pa ih kompakcija ne brise ispod iteratora.
+func (m *Manager) Sources(lower, upper string) []iterator.Source {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	inRange := func(t *table) bool {
+		return t.maxKey >= lower && (upper == "" || t.minKey < upper)
+	}
+
+	var out []iterator.Source
+	l0 := m.levels[0]
+	for i := len(l0) - 1; i >= 0; i-- {
+		if inRange(l0[i]) {
+			l0[i].refs.Add(1)
+			out = append(out, &tableSource{m: m, t: l0[i], own: true})
+		}
+	}
+	for _, tables := range m.levels[1:] {
+		var lt []*table
+		for _, t := range tables {
+			if inRange(t) {
+				t.refs.Add(1)
+				lt = append(lt, t)
+			}
+		}
+		if len(lt) > 0 {
+			out = append(out, &levelSource{m: m, tables: lt})
+		}
+	}
+	return out
+}
+
+// tableSource je izvor nad jednom tabelom. Pri prvom pozicioniranju ucitava
+// ceo index (kljuc i offset svakog zapisa), pa se po tabeli krece u oba
+// smera, a zapisi se citaju preko block cache-a. Tabele bez index-a (starije
+// verzije) se ucitavaju cele.
+type tableSource struct {
+	m   *Manager
+	t   *table
+	own bool // Close pusta referencu na t
+
+	loaded  bool
+	entries []model.IndexEntry
+	recs    []model.Record // samo za tabele bez index-a
+
+	i   int
+	rec model.Record
+	err error
+}
+
+func (s *tableSource) load() {
+	if s.loaded {
+		return
+	}
+	s.loaded = true
+
+	if !s.t.index.exists() {
+		err := s.m.readRecords(s.t, func(r model.Record) bool {
+			s.recs = append(s.recs, r)
+			return true
+		})
+		// odsecen rep, isto kao pri startu
+		if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+			s.err = err
+		}
+		return
+	}
+
+	buf, err := s.m.readAll(s.t.index)
+	if err != nil {
+		s.err = err
+		return
+	}
+	r := newSectionReader(bytes.NewReader(buf), int64(len(buf)))
+	for {
+		e, err := decodeIndexEntry(r)
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			s.err = err
+			return
+		}
+		s.entries = append(s.entries, e)
+	}
+}
+
+func (s *tableSource) len() int {
+	if s.recs != nil {
+		return len(s.recs)
+	}
+	return len(s.entries)
+}
+
+func (s *tableSource) key(i int) string {
+	if s.recs != nil {
+		return s.recs[i].Key
+	}
+	return s.entries[i].Key
+}
+
+// seek namesta izvor na i-ti zapis i cita ga.
+func (s *tableSource) seek(i int) {
+	s.i = i
+	if s.err != nil || !s.Valid() || s.recs != nil {
+		return
+	}
+	s.rec, s.err = s.m.readRecordAt(s.t, s.entries[i].DataOffset)
+}
+
+// search vraca indeks prvog zapisa sa kljucem >= key.
+func (s *tableSource) search(key string) int {
+	return sort.Search(s.len(), func(i int) bool { return s.key(i) >= key })
+}
+
+func (s *tableSource) First() {
+	s.load()
+	s.seek(0)
+}
+
+func (s *tableSource) Last() {
+	s.load()
+	s.seek(s.len() - 1)
+}
+
+func (s *tableSource) SeekGE(key string) {
+	s.load()
+	s.seek(s.search(key))
+}
+
+func (s *tableSource) SeekLT(key string) {
+	s.load()
+	s.seek(s.search(key) - 1)
+}
+
+func (s *tableSource) Next() { s.seek(s.i + 1) }
+
+func (s *tableSource) Prev() { s.seek(s.i - 1) }
+
+func (s *tableSource) Valid() bool {
+	return s.err == nil && s.i >= 0 && s.i < s.len()
+}
+
+func (s *tableSource) Record() model.Record {
+	if s.recs != nil {
+		return s.recs[s.i]
+	}
+	return s.rec
+}
+
+func (s *tableSource) Err() error {
+	return s.err
+}
+
+func (s *tableSource) Close() error {
+	if s.own {
+		s.m.unref(s.t)
+		s.own = false
+	}
+	return nil
+}
+
+// levelSource je izvor nad jednim nivoom >= 1: tabele su disjunktne i
+// sortirane po kljucu, pa se citaju jedna za drugom. U memoriji je samo
+// index tabele na kojoj je izvor trenutno.
+type levelSource struct {
+	m      *Manager
+	tables []*table
+	cur    int
+	src    *tableSource
+}
+
+func (l *levelSource) open(i int) *tableSource {
+	l.cur = i
+	l.src = &tableSource{m: l.m, t: l.tables[i]}
+	return l.src
+}
+
+// skipForward prelazi na sledece tabele dok izvor nije na nekom zapisu.
+func (l *levelSource) skipForward() {
+	for !l.src.Valid() && l.src.Err() == nil && l.cur+1 < len(l.tables) {
+		l.open(l.cur + 1).First()
+	}
+}
+
+func (l *levelSource) skipBackward() {
+	for !l.src.Valid() && l.src.Err() == nil && l.cur > 0 {
+		l.open(l.cur - 1).Last()
+	}
+}
+
+func (l *levelSource) First() {
+	l.open(0).First()
+	l.skipForward()
+}
+
+func (l *levelSource) Last() {
+	l.open(len(l.tables) - 1).Last()
+	l.skipBackward()
+}
+
+func (l *levelSource) SeekGE(key string) {
+	i := sort.Search(len(l.tables), func(i int) bool { return l.tables[i].maxKey >= key })
+	if i == len(l.tables) {
+		// iza poslednje tabele
+		l.open(i - 1).SeekGE(key)
+		return
+	}
+	l.open(i).SeekGE(key)
+	l.skipForward()
+}
+
+func (l *levelSource) SeekLT(key string) {
+	i := sort.Search(len(l.tables), func(i int) bool { return l.tables[i].minKey >= key }) - 1
+	if i < 0 {
+		// ispred prve tabele
+		l.open(0).SeekLT(key)
+		return
+	}
+	l.open(i).SeekLT(key)
+	l.skipBackward()
+}
+
+func (l *levelSource) Next() {
+	l.src.Next()
+	l.skipForward()
+}
+
+func (l *levelSource) Prev() {
+	l.src.Prev()
+	l.skipBackward()
+}
+
+func (l *levelSource) Valid() bool { return l.src != nil && l.src.Valid() }
+
+func (l *levelSource) Record() model.Record { return l.src.Record() }
+
+func (l *levelSource) Err() error {
+	if l.src == nil {
+		return nil
+	}
+	return l.src.Err()
+}
+
+func (l *levelSource) Close() error {
+	l.m.release(l.tables)
+	l.tables = nil
+	return nil
+}
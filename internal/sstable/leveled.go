@@ -0,0 +1,87 @@
+package sstable
+
+import "sort"
+
+// leveled kompakcija:
+//   - L0 se spaja u L1 kada ima l0Trigger tabela: sve L0 tabele i L1 tabele
+//     koje se sa njima preklapaju postaju nove L1 tabele.
+//   - nivo i >= 1 se spaja nanize kada predje levelBaseBytes * levelMultiplier^(i-1):
+//     jedna tabela sa nivoa i (redom po kljucu) i preklapajuce tabele sa nivoa i+1.
+//
+// Na nivoima >= 1 tabele tako ostaju disjunktne, pa Get cita najvise jednu
+// tabelu po nivou.
+type leveled struct {
+	l0Trigger       int   // broj L0 tabela posle kog se L0 spaja u L1
+	levelBaseBytes  int64 // granica velicine L1
+	levelMultiplier int   // svaki sledeci nivo je toliko puta veci
+	targetFileBytes int64 // priblizna velicina tabela koje pravi kompakcija
+
+	// poslednji kompaktovani kljuc po nivou, da bi se nivo kompaktovao u krug
+	compactPtr []string
+}
+
+func (l *leveled) Name() string {
+	return "leveled"
+}
+
+// levelTarget je granica velicine nivoa >= 1.
+func (l *leveled) levelTarget(level int) int64 {
+	target := l.levelBaseBytes
+	for i := 1; i < level; i++ {
+		target *= int64(l.levelMultiplier)
+	}
+	return target
+}
+
+// pick bira nivo koji najvise prelazi svoju granicu. Poslednji nivo se ne
+// spaja nanize.
+func (l *leveled) pick(levels [][]*table) *compaction {
+	if len(l.compactPtr) < len(levels) {
+		l.compactPtr = append(l.compactPtr, make([]string, len(levels)-len(l.compactPtr))...)
+	}
+
+	best, bestScore := -1, 1.0
+	for level := 0; level < len(levels)-1; level++ {
+		var score float64
+		if level == 0 {
+			score = float64(len(levels[0])) / float64(l.l0Trigger)
+		} else {
+			var size int64
+			for _, t := range levels[level] {
+				size += t.size()
+			}
+			score = float64(size) / float64(l.levelTarget(level))
+		}
+		if score >= bestScore {
+			best, bestScore = level, score
+		}
+	}
+	if best < 0 {
+		return nil
+	}
+
+	c := &compaction{level: best, outLevel: best + 1, splitBytes: l.targetFileBytes}
+	if best == 0 {
+		c.inputs = append(c.inputs, levels[0]...)
+	} else {
+		// sledeca tabela posle prethodno kompaktovane, u krug po kljucu
+		tables := levels[best]
+		i := sort.Search(len(tables), func(i int) bool { return tables[i].minKey > l.compactPtr[best] })
+		if i == len(tables) {
+			i = 0
+		}
+		c.inputs = []*table{tables[i]}
+		l.compactPtr[best] = tables[i].maxKey
+	}
+
+	minKey, maxKey := keyRange(c.inputs)
+	for _, t := range levels[c.outLevel] {
+		if t.overlaps(minKey, maxKey) {
+			c.overlap = append(c.overlap, t)
+		}
+	}
+
+	all := append(append([]*table{}, c.inputs...), c.overlap...)
+	c.bottom = noOlderVersions(levels, c.outLevel, all)
+	return c
+}
@@ -0,0 +1,250 @@
+package sstable
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"kv-engine/internal/model"
+)
+
+// Tabele su rasporedjene po nivoima, svaki nivo u svom direktorijumu
+// (sstable/level0, sstable/level1, ...). L0 dobija tabele iz flush-a i one
+// mogu da se preklapaju; na nivoima >= 1 tabele pokrivaju disjunktne opsege
+// kljuceva i sortirane su po kljucu.
+
+func (m *Manager) levelDir(level int) string {
+	return filepath.Join(m.dir, fmt.Sprintf("level%d", level))
+}
+
+// loadLevels otvara tabele iz manifest-a. Store bez MANIFEST-a (iz vremena
+// pre njega) se prevodi: tabele iz manifest.json, odnosno sve tabele iz
+// level0, se proveravaju, ucitavaju im se granice i upisuju se u novi
+// MANIFEST. Na kraju se brisu fajlovi koji ne pripadaju nijednoj zivoj
+// tabeli, osim kada je iz MANIFEST-a nekad odbacen rep: tada siroce moze
+// biti tabela iz izmene koja je bila trajna, pa se fajlovi ostavljaju dok se
+// MANIFEST.torn ne pregleda i obrise.
+func (m *Manager) loadLevels() error {
+	layout, fromScan, keepOrphans, err := m.loadManifest()
+	if err != nil {
+		return err
+	}
+
+	m.levels = make([][]*table, max(m.maxLevels, len(layout)))
+	for level, tes := range layout {
+		for _, te := range tes {
+			t, err := m.openTable(filepath.Join(m.levelDir(level), te.name), level)
+			if err == errBadFooter && fromScan {
+				// prekinut flush; njegovi zapisi su jos u WAL-u
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("%s: %w", te.name, err)
+			}
+			if fromScan {
+				if err := m.loadBounds(t); err != nil {
+					return fmt.Errorf("%s: %w", te.name, err)
+				}
+			} else {
+				t.minKey, t.maxKey = te.minKey, te.maxKey
+				t.minSeq, t.maxSeq = te.minSeq, te.maxSeq
+			}
+			m.levels[level] = append(m.levels[level], t)
+		}
+	}
+	for _, tables := range m.levels[1:] {
+		sort.Slice(tables, func(i, j int) bool { return tables[i].minKey < tables[j].minKey })
+	}
+
+	if fromScan {
+		m.manifest, err = createManifest(filepath.Join(m.dir, manifestFile), m.snapshot())
+		if err != nil {
+			return err
+		}
+		if err := os.Remove(filepath.Join(m.dir, legacyManifestFile)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if keepOrphans {
+		return nil
+	}
+	return m.removeOrphans()
+}
+
+// legacyLevels vraca imena tabela po nivoima iz manifest.json, a ako ni on
+// ne postoji, sve tabele iz level0.
+func legacyLevels(dir string) ([][]string, error) {
+	b, err := os.ReadFile(filepath.Join(dir, legacyManifestFile))
+	if os.IsNotExist(err) {
+		names, err := tableNames(filepath.Join(dir, "level0"))
+		if err != nil {
+			return nil, err
+		}
+		return [][]string{names}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var mf struct {
+		Levels [][]string `json:"levels"`
+	}
+	if err := json.Unmarshal(b, &mf); err != nil {
+		return nil, fmt.Errorf("%s: %w", legacyManifestFile, err)
+	}
+	return mf.Levels, nil
+}
+
+// removeOrphans brise fajlove u direktorijumima nivoa koji ne pripadaju
+// nijednoj zivoj tabeli: ostatke prekinutih flush-eva i kompakcija i tabele
+// cije brisanje nije stiglo da se zavrsi.
+func (m *Manager) removeOrphans() error {
+	live := make(map[string]bool)
+	for _, tables := range m.levels {
+		for _, t := range tables {
+			live[t.name] = true
+		}
+	}
+
+	dirs, err := filepath.Glob(filepath.Join(m.dir, "level*"))
+	if err != nil {
+		return err
+	}
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			name := e.Name()
+			base := filepath.Join(dir, strings.TrimSuffix(name, filepath.Ext(name)))
+			if e.IsDir() || live[base] {
+				continue
+			}
+			if err := os.Remove(filepath.Join(dir, name)); err != nil {
+				return err
+			}
+		}
+	}
+
+	// ostatak prekinutog prepisivanja manifest-a
+	if err := os.Remove(filepath.Join(m.dir, manifestFile+".tmp")); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// tableNames vraca imena svih tabela u direktorijumu, sortirana.
+func tableNames(dir string) ([]string, error) {
+	var names []string
+	seen := make(map[string]bool)
+	for _, ext := range []string{sstExt, dataExt} {
+		files, err := filepath.Glob(filepath.Join(dir, "*"+ext))
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range files {
+			name := strings.TrimSuffix(filepath.Base(f), ext)
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// loadBounds jednim prolazom kroz data deo racuna granice kljuceva i seq-ova.
+func (m *Manager) loadBounds(t *table) error {
+	first := true
+	err := m.readRecords(t, func(r model.Record) bool {
+		if first {
+			t.minKey, t.minSeq = r.Key, r.Seq
+			first = false
+		}
+		t.maxKey = r.Key
+		t.minSeq = min(t.minSeq, r.Seq)
+		t.maxSeq = max(t.maxSeq, r.Seq)
+		return true
+	})
+	// odsecen rep (prekinut flush) ne sme da obori start, vazi ono sto je procitano
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return err
+	}
+	return nil
+}
+
+// acquire vraca tabele koje mogu da sadrze kljuc, redom kojim ih treba
+// pretraziti (od novijih podataka ka starijim), i drzi referencu na svaku
+// dok se ne pozove release.
+func (m *Manager) acquire(key string) []*table {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var out []*table
+	l0 := m.levels[0]
+	for i := len(l0) - 1; i >= 0; i-- {
+		if l0[i].covers(key) {
+			out = append(out, l0[i])
+		}
+	}
+	for _, tables := range m.levels[1:] {
+		i := sort.Search(len(tables), func(i int) bool { return tables[i].maxKey >= key })
+		if i < len(tables) && tables[i].covers(key) {
+			out = append(out, tables[i])
+		}
+	}
+
+	for _, t := range out {
+		t.refs.Add(1)
+	}
+	return out
+}
+
+// acquireAll vraca sve zive tabele, nivo po nivo, uz referencu na svaku.
+func (m *Manager) acquireAll() []*table {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var out []*table
+	for _, tables := range m.levels {
+		for _, t := range tables {
+			t.refs.Add(1)
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+func (m *Manager) release(tables []*table) {
+	for _, t := range tables {
+		m.unref(t)
+	}
+}
+
+// unref pusta jednu referencu; poslednja brise fajlove tabele.
+func (m *Manager) unref(t *table) {
+	if t.refs.Add(-1) > 0 {
+		return
+	}
+
+	// greske pri brisanju se ne prijavljuju: tabela vise nije u manifest-u,
+	// pa zaostali fajl ne utice na citanje
+	for _, f := range t.files() {
+		os.Remove(f)
+	}
+
+	m.summaryMu.Lock()
+	delete(m.summaries, t.name)
+	m.summaryMu.Unlock()
+
+	m.filterMu.Lock()
+	delete(m.filters, t.name)
+	m.filterMu.Unlock()
+}
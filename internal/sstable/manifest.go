@@ -0,0 +1,438 @@
+package sstable
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+
+	"kv-engine/internal/fileutil"
+)
+
+// MANIFEST u sstable direktorijumu je log izmena skupa zivih tabela. Svaki
+// zapis je jedna atomska izmena (flush dodaje tabelu, kompakcija brise ulaze
+// i dodaje izlaze), pa se pri startu raspored tabela dobija ponavljanjem
+// izmena redom. Citanje vidi samo tabele iz manifest-a: fajl koji nije u
+// njemu (prekinut flush ili kompakcija) je siroce i brise se pri startu.
+//
+// Format fajla (BigEndian):
+//
+//	[magic u32][version u32]
+//	zapisi: [crc u32][len u32][payload]
+//	payload: [nextFile u64][count uvarint] count x izmena tabele
+//	izmena: [op u8][level uvarint][name][minKey][maxKey][minSeq uvarint][maxSeq uvarint]
+//	        (stringovi kao [len uvarint][bytes]; brisanje nosi samo op, level i name)
+//
+// Kada log naraste preko manifestMaxEdits zapisa, prepisuje se atomski kao
+// jedan zapis sa trenutnim stanjem.
+const (
+	manifestFile = "MANIFEST"
+
+	manifestMagic   uint32 = 0x4d4e4654 // "MNFT"
+	manifestVersion uint32 = 1
+
+	manifestMaxEdits = 1000
+
+	// odbaceni rep MANIFEST-a se cuva u MANIFEST.torn
+	tornExt = ".torn"
+
+	// manifest.json je prethodni format (samo imena po nivoima); pri startu se
+	// prevodi u MANIFEST i brise
+	legacyManifestFile = "manifest.json"
+)
+
+const (
+	opAdd    byte = 1
+	opDelete byte = 2
+)
+
+var errBadManifest = errors.New("sstable: corrupted manifest")
+
+// tableEdit dodaje ili brise jednu tabelu sa nivoa.
+type tableEdit struct {
+	op             byte
+	level          int
+	name           string // sst_<broj> (ili sst_<nanos> za starije tabele)
+	minKey, maxKey string
+	minSeq, maxSeq uint64
+}
+
+// versionEdit je jedan zapis u manifest-u.
+type versionEdit struct {
+	nextFile uint64 // sledeci slobodan broj fajla
+	tables   []tableEdit
+}
+
+func addEdit(t *table) tableEdit {
+	return tableEdit{
+		op:     opAdd,
+		level:  t.level,
+		name:   t.id(),
+		minKey: t.minKey,
+		maxKey: t.maxKey,
+		minSeq: t.minSeq,
+		maxSeq: t.maxSeq,
+	}
+}
+
+func deleteEdit(t *table) tableEdit {
+	return tableEdit{op: opDelete, level: t.level, name: t.id()}
+}
+
+func encodeEdit(e versionEdit) []byte {
+	var payload []byte
+	payload = binary.BigEndian.AppendUint64(payload, e.nextFile)
+	payload = binary.AppendUvarint(payload, uint64(len(e.tables)))
+
+	putString := func(s string) {
+		payload = binary.AppendUvarint(payload, uint64(len(s)))
+		payload = append(payload, s...)
+	}
+	for _, t := range e.tables {
+		payload = append(payload, t.op)
+		payload = binary.AppendUvarint(payload, uint64(t.level))
+		putString(t.name)
+		if t.op == opAdd {
+			putString(t.minKey)
+			putString(t.maxKey)
+			payload = binary.AppendUvarint(payload, t.minSeq)
+			payload = binary.AppendUvarint(payload, t.maxSeq)
+		}
+	}
+
+	buf := make([]byte, 8, 8+len(payload))
+	binary.BigEndian.PutUint32(buf[0:], crc32.ChecksumIEEE(payload))
+	binary.BigEndian.PutUint32(buf[4:], uint32(len(payload)))
+	return append(buf, payload...)
+}
+
+func decodeEdit(payload []byte) (versionEdit, error) {
+	r := bytes.NewReader(payload)
+	var e versionEdit
+
+	if err := binary.Read(r, binary.BigEndian, &e.nextFile); err != nil {
+		return versionEdit{}, errBadManifest
+	}
+	count, err := binary.ReadUvarint(r)
+	if err != nil {
+		return versionEdit{}, errBadManifest
+	}
+
+	readString := func() (string, error) {
+		n, err := binary.ReadUvarint(r)
+		if err != nil || n > uint64(r.Len()) {
+			return "", errBadManifest
+		}
+		b := make([]byte, n)
+		io.ReadFull(r, b)
+		return string(b), nil
+	}
+	for i := uint64(0); i < count; i++ {
+		var t tableEdit
+		if t.op, err = r.ReadByte(); err != nil || (t.op != opAdd && t.op != opDelete) {
+			return versionEdit{}, errBadManifest
+		}
+		level, err := binary.ReadUvarint(r)
+		if err != nil {
+			return versionEdit{}, errBadManifest
+		}
+		t.level = int(level)
+		if t.name, err = readString(); err != nil {
+			return versionEdit{}, err
+		}
+		if t.op == opAdd {
+			if t.minKey, err = readString(); err != nil {
+				return versionEdit{}, err
+			}
+			if t.maxKey, err = readString(); err != nil {
+				return versionEdit{}, err
+			}
+			if t.minSeq, err = binary.ReadUvarint(r); err != nil {
+				return versionEdit{}, errBadManifest
+			}
+			if t.maxSeq, err = binary.ReadUvarint(r); err != nil {
+				return versionEdit{}, errBadManifest
+			}
+		}
+		e.tables = append(e.tables, t)
+	}
+	return e, nil
+}
+
+// manifestLog je otvoren MANIFEST u koji se dopisuju izmene.
+type manifestLog struct {
+	path  string
+	f     *os.File
+	edits int // zapisa u fajlu; posle manifestMaxEdits log se prepisuje
+}
+
+// readManifest cita sve izmene iz MANIFEST-a; ok=false ako ne postoji.
+// Odbacuje se samo odsecen rep (prekinut upis): zapis cija duzina prelazi
+// kraj fajla ili ostecen poslednji zapis, i to samo ako iza njega nema
+// nijednog ispravnog zapisa. Tada se odbaceni bajtovi cuvaju u
+// MANIFEST.torn, fajl se skracuje na poslednji ispravan zapis i truncated
+// je true. Svako drugo ostecenje je greska, jer bi odbacivanje izgubilo
+// izmene koje su vec trajne.
+func readManifest(path string) (edits []versionEdit, ok, truncated bool, err error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, false, false, nil
+	}
+	if err != nil {
+		return nil, false, false, err
+	}
+	if len(data) < 8 || binary.BigEndian.Uint32(data) != manifestMagic {
+		return nil, false, false, errBadManifest
+	}
+	if v := binary.BigEndian.Uint32(data[4:]); v != manifestVersion {
+		return nil, false, false, fmt.Errorf("sstable: unsupported manifest version %d", v)
+	}
+
+	off := 8
+	for off < len(data) {
+		if len(data)-off < 8 {
+			break
+		}
+		crc := binary.BigEndian.Uint32(data[off:])
+		n := int(binary.BigEndian.Uint32(data[off+4:]))
+		if n > len(data)-off-8 {
+			// ostecena duzina takodje pokazuje iza kraja fajla
+			if recordAfter(data, off) {
+				return nil, false, false, fmt.Errorf("%w: bad record length at offset %d", errBadManifest, off)
+			}
+			break
+		}
+		payload := data[off+8 : off+8+n]
+		e, err := decodeEdit(payload)
+		if crc32.ChecksumIEEE(payload) != crc || err != nil {
+			if off+8+n == len(data) {
+				break
+			}
+			return nil, false, false, fmt.Errorf("%w: bad record at offset %d", errBadManifest, off)
+		}
+		edits = append(edits, e)
+		off += 8 + n
+	}
+
+	if off < len(data) {
+		if err := saveTorn(path, data[off:]); err != nil {
+			return nil, false, false, err
+		}
+		if err := os.Truncate(path, int64(off)); err != nil {
+			return nil, false, false, err
+		}
+		truncated = true
+	}
+	return edits, true, truncated, nil
+}
+
+// recordAfter javlja da li negde iza from pocinje ispravan zapis.
+func recordAfter(data []byte, from int) bool {
+	for off := from + 1; off+8 <= len(data); off++ {
+		n := int(binary.BigEndian.Uint32(data[off+4:]))
+		if n > len(data)-off-8 {
+			continue
+		}
+		payload := data[off+8 : off+8+n]
+		if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(data[off:]) {
+			continue
+		}
+		if _, err := decodeEdit(payload); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// saveTorn trajno dopisuje odbaceni rep u MANIFEST.torn. Dok taj fajl
+// postoji siroad se ne brise: ako je rep ipak bio trajna izmena, njene
+// tabele su jedina kopija podataka.
+func saveTorn(path string, tail []byte) error {
+	f, err := os.OpenFile(path+tornExt, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(tail); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return fileutil.SyncDir(filepath.Dir(path))
+}
+
+// createManifest atomski pravi novi MANIFEST sa jednim zapisom (preko
+// privremenog fajla i rename-a) i ostavlja ga otvorenog za dopisivanje.
+func createManifest(path string, snapshot versionEdit) (*manifestLog, error) {
+	data := binary.BigEndian.AppendUint32(nil, manifestMagic)
+	data = binary.BigEndian.AppendUint32(data, manifestVersion)
+	data = append(data, encodeEdit(snapshot)...)
+
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := fileutil.SyncDir(filepath.Dir(path)); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &manifestLog{path: path, f: f, edits: 1}, nil
+}
+
+func openManifestLog(path string, edits int) (*manifestLog, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &manifestLog{path: path, f: f, edits: edits}, nil
+}
+
+// append trajno dopisuje jednu izmenu.
+func (l *manifestLog) append(e versionEdit) error {
+	if _, err := l.f.Write(encodeEdit(e)); err != nil {
+		return err
+	}
+	if err := l.f.Sync(); err != nil {
+		return err
+	}
+	l.edits++
+	return nil
+}
+
+func (l *manifestLog) close() error {
+	return l.f.Close()
+}
+
+// snapshot je izmena koja od praznog stanja pravi trenutni raspored tabela.
+// Poziva se pod m.mu.
+func (m *Manager) snapshot() versionEdit {
+	e := versionEdit{nextFile: m.nextFile.Load()}
+	for _, tables := range m.levels {
+		for _, t := range tables {
+			e.tables = append(e.tables, addEdit(t))
+		}
+	}
+	return e
+}
+
+// logEdit upisuje izmenu u manifest, a kada log postane predugacak prepisuje
+// ga trenutnim stanjem. Poziva se pod m.mu, posle izmene m.levels.
+func (m *Manager) logEdit(tables ...tableEdit) error {
+	if err := m.manifest.append(versionEdit{nextFile: m.nextFile.Load(), tables: tables}); err != nil {
+		return err
+	}
+	if m.manifest.edits < manifestMaxEdits {
+		return nil
+	}
+
+	// izmena je vec trajna, pa neuspelo prepisivanje nije greska izmene;
+	// pokusava se ponovo pri sledecoj izmeni
+	l, err := createManifest(m.manifest.path, m.snapshot())
+	if err != nil {
+		return nil
+	}
+	m.manifest.close()
+	m.manifest = l
+	return nil
+}
+
+// loadManifest vraca raspored tabela iz MANIFEST-a (ili iz starijeg
+// manifest.json, odnosno iz level0 za store bez ikakvog manifest-a).
+// fromScan je true kada raspored nije procitan iz MANIFEST-a, pa tabele
+// treba proveriti i ucitati im granice. keepOrphans je true kada je rep
+// MANIFEST-a nekad odbacen (postoji MANIFEST.torn).
+func (m *Manager) loadManifest() (levels [][]tableEdit, fromScan, keepOrphans bool, err error) {
+	path := filepath.Join(m.dir, manifestFile)
+	edits, ok, _, err := readManifest(path)
+	if err != nil {
+		return nil, false, false, fmt.Errorf("%s: %w", manifestFile, err)
+	}
+	if _, err := os.Stat(path + tornExt); err == nil {
+		keepOrphans = true
+	} else if !os.IsNotExist(err) {
+		return nil, false, false, err
+	}
+
+	if !ok {
+		names, err := legacyLevels(m.dir)
+		if err != nil {
+			return nil, false, false, err
+		}
+		levels = make([][]tableEdit, len(names))
+		for level, ns := range names {
+			for _, name := range ns {
+				levels[level] = append(levels[level], tableEdit{op: opAdd, level: level, name: name})
+			}
+		}
+		return levels, true, keepOrphans, nil
+	}
+
+	for _, e := range edits {
+		m.nextFile.Store(max(m.nextFile.Load(), e.nextFile))
+		levels = e.apply(levels)
+	}
+
+	m.manifest, err = openManifestLog(path, len(edits))
+	if err != nil {
+		return nil, false, false, err
+	}
+	return levels, false, keepOrphans, nil
+}
+
+// apply primenjuje izmenu na raspored tabela. Tabela dodata na nivo sa kog
+// je ista izmena obrisala tabele zauzima mesto prve obrisane (size-tiered
+// kompakcija u L0 ne sme da promeni redosled po starosti); ostale se
+// dodaju na kraj nivoa.
+func (e versionEdit) apply(levels [][]tableEdit) [][]tableEdit {
+	insertAt := make(map[int]int)
+	for _, t := range e.tables {
+		for len(levels) <= t.level {
+			levels = append(levels, nil)
+		}
+		lt := levels[t.level]
+
+		if t.op == opDelete {
+			for i := range lt {
+				if lt[i].name == t.name {
+					levels[t.level] = append(lt[:i:i], lt[i+1:]...)
+					if pos, ok := insertAt[t.level]; !ok || i < pos {
+						insertAt[t.level] = i
+					}
+					break
+				}
+			}
+			continue
+		}
+
+		pos, ok := insertAt[t.level]
+		if !ok {
+			levels[t.level] = append(lt, t)
+			continue
+		}
+		levels[t.level] = append(lt[:pos:pos], append([]tableEdit{t}, lt[pos:]...)...)
+		insertAt[t.level] = pos + 1
+	}
+	return levels
+}
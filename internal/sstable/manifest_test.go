@@ -0,0 +1,248 @@
+package sstable
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"kv-engine/internal/block"
+	"kv-engine/internal/config"
+	"kv-engine/internal/model"
+)
+
+func openTestManager(t *testing.T, dir string) *Manager {
+	t.Helper()
+	m, err := New(dir, config.Default(), block.NewBlockManager(64))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return m
+}
+
+// flushTables pravi n L0 tabela sa po jednim kljucem k<i>.
+func flushTables(t *testing.T, m *Manager, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		rec := model.Record{Key: fmt.Sprintf("k%d", i), Value: []byte("v"), Seq: uint64(i + 1)}
+		if err := m.Flush([]model.Record{rec}); err != nil {
+			t.Fatalf("Flush: %v", err)
+		}
+	}
+}
+
+func tableCount(m *Manager) int {
+	n := 0
+	for _, tables := range m.levels {
+		n += len(tables)
+	}
+	return n
+}
+
+func mustGet(t *testing.T, m *Manager, key string) {
+	t.Helper()
+	r, err := m.Get(key, ^uint64(0))
+	if err != nil || !r.Found {
+		t.Fatalf("Get(%s) = %+v, %v", key, r, err)
+	}
+}
+
+func TestManifestReplay(t *testing.T) {
+	dir := t.TempDir()
+	m := openTestManager(t, dir)
+	flushTables(t, m, 3)
+	m.Close()
+
+	m = openTestManager(t, dir)
+	defer m.Close()
+	if n := tableCount(m); n != 3 {
+		t.Fatalf("tables after reopen = %d, want 3", n)
+	}
+	for i := 0; i < 3; i++ {
+		mustGet(t, m, fmt.Sprintf("k%d", i))
+	}
+}
+
+func TestManifestTornTail(t *testing.T) {
+	dir := t.TempDir()
+	m := openTestManager(t, dir)
+	flushTables(t, m, 2)
+	m.Close()
+
+	path := filepath.Join(dir, manifestFile)
+	info, _ := os.Stat(path)
+	// pocetak zapisa koji nije stigao da se upise do kraja
+	rec := encodeEdit(versionEdit{nextFile: 99, tables: []tableEdit{{op: opAdd, name: "sst_000099"}}})
+	f, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	f.Write(rec[:len(rec)-3])
+	f.Close()
+
+	m = openTestManager(t, dir)
+	defer m.Close()
+	if n := tableCount(m); n != 2 {
+		t.Fatalf("tables = %d, want 2", n)
+	}
+	if after, _ := os.Stat(path); after.Size() != info.Size() {
+		t.Fatalf("manifest size = %d, want %d", after.Size(), info.Size())
+	}
+}
+
+func TestManifestCorruptLastRecord(t *testing.T) {
+	dir := t.TempDir()
+	m := openTestManager(t, dir)
+	flushTables(t, m, 2)
+	m.Close()
+
+	path := filepath.Join(dir, manifestFile)
+	data, _ := os.ReadFile(path)
+	data[len(data)-1] ^= 0x01
+	os.WriteFile(path, data, 0644)
+
+	_, _, truncated, err := readManifest(path)
+	if err != nil || !truncated {
+		t.Fatalf("readManifest: truncated=%v err=%v, want truncated", truncated, err)
+	}
+}
+
+func TestManifestCorruptMiddle(t *testing.T) {
+	dir := t.TempDir()
+	m := openTestManager(t, dir)
+	flushTables(t, m, 3)
+	m.Close()
+
+	before, _ := filepath.Glob(filepath.Join(dir, "level0", "*"))
+
+	// bit u payload-u prvog zapisa (iza zaglavlja fajla i zapisa)
+	path := filepath.Join(dir, manifestFile)
+	data, _ := os.ReadFile(path)
+	data[8+8+2] ^= 0x01
+	os.WriteFile(path, data, 0644)
+
+	_, err := New(dir, config.Default(), block.NewBlockManager(64))
+	if !errors.Is(err, errBadManifest) {
+		t.Fatalf("New: %v, want errBadManifest", err)
+	}
+	after, _ := filepath.Glob(filepath.Join(dir, "level0", "*"))
+	if len(after) != len(before) {
+		t.Fatalf("table files: %d before, %d after failed open", len(before), len(after))
+	}
+}
+
+func TestManifestCorruptLength(t *testing.T) {
+	dir := t.TempDir()
+	m := openTestManager(t, dir)
+	flushTables(t, m, 3)
+	m.Close()
+
+	before, _ := filepath.Glob(filepath.Join(dir, "level0", "*"))
+
+	// duzina prvog zapisa pokazuje iza kraja fajla, ali iza nje su ispravni zapisi
+	path := filepath.Join(dir, manifestFile)
+	data, _ := os.ReadFile(path)
+	data[8+4] ^= 0x80
+	os.WriteFile(path, data, 0644)
+
+	for i := 0; i < 2; i++ {
+		_, err := New(dir, config.Default(), block.NewBlockManager(64))
+		if !errors.Is(err, errBadManifest) {
+			t.Fatalf("New #%d: %v, want errBadManifest", i+1, err)
+		}
+	}
+	if after, _ := os.ReadFile(path); len(after) != len(data) {
+		t.Fatalf("manifest truncated to %d bytes", len(after))
+	}
+	after, _ := filepath.Glob(filepath.Join(dir, "level0", "*"))
+	if len(after) != len(before) {
+		t.Fatalf("table files: %d before, %d after failed open", len(before), len(after))
+	}
+}
+
+func TestManifestTornTailKeepsOrphans(t *testing.T) {
+	dir := t.TempDir()
+	m := openTestManager(t, dir)
+	flushTables(t, m, 2)
+	m.Close()
+
+	// tabela iz izmene koja se izgubila sa repom
+	orphan := filepath.Join(dir, "level0", "sst_000099"+dataExt)
+	os.WriteFile(orphan, []byte("data"), 0644)
+
+	path := filepath.Join(dir, manifestFile)
+	rec := encodeEdit(versionEdit{nextFile: 100, tables: []tableEdit{{op: opAdd, name: "sst_000099"}}})
+	f, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	f.Write(rec[:len(rec)-3])
+	f.Close()
+
+	// ni start koji skracuje log ni sledeci ne smeju da obrisu siroce
+	for i := 0; i < 2; i++ {
+		m = openTestManager(t, dir)
+		m.Close()
+		if _, err := os.Stat(orphan); err != nil {
+			t.Fatalf("reopen #%d: %v", i+1, err)
+		}
+	}
+	torn, _ := os.ReadFile(path + tornExt)
+	if len(torn) != len(rec)-3 {
+		t.Fatalf("%s has %d bytes, want %d", manifestFile+tornExt, len(torn), len(rec)-3)
+	}
+
+	// kada se MANIFEST.torn obrise, siroce se cisti
+	os.Remove(path + tornExt)
+	m = openTestManager(t, dir)
+	defer m.Close()
+	if _, err := os.Stat(orphan); !os.IsNotExist(err) {
+		t.Fatalf("orphan after %s removed: %v", manifestFile+tornExt, err)
+	}
+}
+
+func TestManifestRewrite(t *testing.T) {
+	dir := t.TempDir()
+	m := openTestManager(t, dir)
+	flushTables(t, m, 2)
+
+	// sledeca izmena prepisuje log trenutnim stanjem
+	m.manifest.edits = manifestMaxEdits - 1
+	flushTables(t, m, 1)
+	if m.manifest.edits != 1 {
+		t.Fatalf("edits after rewrite = %d, want 1", m.manifest.edits)
+	}
+	// izmena posle prepisivanja ide u novi fajl
+	rec := model.Record{Key: "z", Value: []byte("v"), Seq: 100}
+	if err := m.Flush([]model.Record{rec}); err != nil {
+		t.Fatal(err)
+	}
+	m.Close()
+
+	edits, ok, truncated, err := readManifest(filepath.Join(dir, manifestFile))
+	if err != nil || !ok || truncated {
+		t.Fatalf("readManifest: ok=%v truncated=%v err=%v", ok, truncated, err)
+	}
+	if len(edits) != 2 {
+		t.Fatalf("edits = %d, want 2", len(edits))
+	}
+
+	m = openTestManager(t, dir)
+	defer m.Close()
+	if n := tableCount(m); n != 4 {
+		t.Fatalf("tables = %d, want 4", n)
+	}
+	mustGet(t, m, "k0")
+	mustGet(t, m, "z")
+}
+
+func TestVersionEditApplyKeepsPosition(t *testing.T) {
+	add := func(name string) tableEdit { return tableEdit{op: opAdd, name: name} }
+	del := func(name string) tableEdit { return tableEdit{op: opDelete, name: name} }
+
+	levels := versionEdit{tables: []tableEdit{add("a"), add("b"), add("c"), add("d")}}.apply(nil)
+	levels = versionEdit{tables: []tableEdit{del("b"), del("c"), add("bc")}}.apply(levels)
+
+	var got []string
+	for _, te := range levels[0] {
+		got = append(got, te.name)
+	}
+	if fmt.Sprint(got) != "[a bc d]" {
+		t.Fatalf("level0 = %v, want [a bc d]", got)
+	}
+}
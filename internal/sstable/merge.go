@@ -0,0 +1,128 @@
+package sstable
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"kv-engine/internal/model"
+)
+
+// tableIter redom cita zapise jedne tabele direktno sa diska, mimo block
+// cache-a, da kompakcija ne bi izbacila blokove koje citaju Get-ovi.
+type tableIter struct {
+	t   *table
+	f   *os.File
+	r   *sectionReader
+	rec model.Record
+	err error
+
+	prio int // manji broj = noviji izvor, za iste kljuceve sa istim seq-om
+}
+
+func openTableIter(t *table, prio int) (*tableIter, error) {
+	f, err := os.Open(t.data.path)
+	if err != nil {
+		return nil, err
+	}
+	r := newSectionReader(io.NewSectionReader(f, t.data.off, t.data.len), t.data.len)
+	return &tableIter{t: t, f: f, r: r, prio: prio}, nil
+}
+
+// next vraca false na kraju tabele ili pri gresci. Tabela sa footer-om je
+// upisana cela, pa je i odsecen zapis ostecenje: kompakcija tada staje i
+// ulazi ostaju, umesto da se tiho izgube zapisi iza njega. Starijoj tabeli
+// bez footer-a se odsecen rep preskace, isto kao pri startu i citanju.
+func (it *tableIter) next() bool {
+	rec, err := decodeRecord(it.r, it.t.version)
+	if err == io.EOF || (it.t.legacy && errors.Is(err, io.ErrUnexpectedEOF)) {
+		return false
+	}
+	if err != nil {
+		it.err = fmt.Errorf("%s: %w", it.t.id(), err)
+		return false
+	}
+	it.rec = rec
+	return true
+}
+
+func (it *tableIter) close() {
+	it.f.Close()
+}
+
+// mergeIter spaja vise sortiranih tabela u jedan niz zapisa sortiran po
+// kljucu, a verzije istog kljuca od novije ka starijoj. Isti zapis (isti
+// kljuc i seq) u vise tabela se vraca jednom, iz novijeg izvora; koje
+// starije verzije ostaju odlucuje pozivalac.
+type mergeIter struct {
+	h       iterHeap
+	rec     model.Record
+	started bool
+	err     error
+}
+
+func newMergeIter(iters []*tableIter) *mergeIter {
+	m := &mergeIter{}
+	for _, it := range iters {
+		if it.next() {
+			m.h = append(m.h, it)
+		} else if it.err != nil {
+			m.err = it.err
+		}
+	}
+	heap.Init(&m.h)
+	return m
+}
+
+func (m *mergeIter) next() bool {
+	for m.err == nil && len(m.h) > 0 {
+		it := m.h[0]
+		rec := it.rec
+		if it.next() {
+			heap.Fix(&m.h, 0)
+		} else {
+			if it.err != nil {
+				m.err = it.err
+				return false
+			}
+			heap.Pop(&m.h)
+		}
+
+		// isti zapis iz starijeg izvora
+		if m.started && rec.Key == m.rec.Key && rec.Seq == m.rec.Seq {
+			continue
+		}
+		m.rec = rec
+		m.started = true
+		return true
+	}
+	return false
+}
+
+type iterHeap []*tableIter
+
+func (h iterHeap) Len() int { return len(h) }
+
+func (h iterHeap) Less(i, j int) bool {
+	a, b := h[i].rec, h[j].rec
+	if a.Key != b.Key {
+		return a.Key < b.Key
+	}
+	if a.Seq != b.Seq {
+		return a.Seq > b.Seq
+	}
+	return h[i].prio < h[j].prio
+}
+
+func (h iterHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *iterHeap) Push(x any) { *h = append(*h, x.(*tableIter)) }
+
+func (h *iterHeap) Pop() any {
+	old := *h
+	it := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return it
+}
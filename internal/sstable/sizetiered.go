@@ -0,0 +1,74 @@
+package sstable
+
+// sizeTiered kompakcija drzi sve nove tabele u L0 i spaja grupe tabela
+// slicne velicine u jednu vecu, pa se tabele vremenom skupljaju u "slojeve"
+// sve vece velicine. Pise manje od leveled kompakcije, ali Get mora da
+// proveri vise tabela.
+//
+// Spajaju se samo uzastopne L0 tabele (po starosti), jer nova tabela zauzima
+// njihovo mesto u L0: da se izmedju njih nadje tabela koja nije spojena,
+// starije verzije iz spojene tabele bi je zaklonile.
+type sizeTiered struct {
+	minThreshold int // najmanji broj slicnih tabela koji se spaja
+}
+
+const (
+	// najvise tabela u jednoj kompakciji
+	sizeTieredMaxThreshold = 32
+
+	// tabela je "slicna" grupi ako joj je velicina u [low, high] x prosek grupe
+	sizeTieredBucketLow  = 0.5
+	sizeTieredBucketHigh = 1.5
+
+	// tabele manje od ovoga se smatraju istom velicinom, da sitni flush-evi
+	// ne bi bili rasuti po grupama
+	sizeTieredMinBytes = 64 << 10
+)
+
+func (s *sizeTiered) Name() string {
+	return "size_tiered"
+}
+
+// pick trazi najduzi niz uzastopnih slicnih L0 tabela (pri istoj duzini onaj
+// sa manjim tabelama, jer se brze spaja).
+func (s *sizeTiered) pick(levels [][]*table) *compaction {
+	l0 := levels[0]
+
+	bestStart, bestLen := 0, 0
+	var bestAvg float64
+	for i := 0; i < len(l0); {
+		sum := float64(tierSize(l0[i]))
+		j := i + 1
+		for j < len(l0) && j-i < sizeTieredMaxThreshold {
+			avg := sum / float64(j-i)
+			size := float64(tierSize(l0[j]))
+			if size < avg*sizeTieredBucketLow || size > avg*sizeTieredBucketHigh {
+				break
+			}
+			sum += size
+			j++
+		}
+
+		n, avg := j-i, sum/float64(j-i)
+		if n >= s.minThreshold && (n > bestLen || n == bestLen && avg < bestAvg) {
+			bestStart, bestLen, bestAvg = i, n, avg
+		}
+		i = j
+	}
+	if bestLen == 0 {
+		return nil
+	}
+
+	c := &compaction{
+		level:    0,
+		outLevel: 0,
+		inputs:   append([]*table{}, l0[bestStart:bestStart+bestLen]...),
+	}
+	// starije verzije mogu biti samo u starijim L0 tabelama ili nizim nivoima
+	c.bottom = bestStart == 0 && noOlderVersions(levels, 0, c.inputs)
+	return c
+}
+
+func tierSize(t *table) int64 {
+	return max(t.size(), sizeTieredMinBytes)
+}
@@ -1,29 +1,112 @@
 package sstable
 
 import (
-	"bufio"
+	"bytes"
 	"encoding/binary"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"sort"
-	"time"
+	"sync"
+	"sync/atomic"
 
+	"kv-engine/internal/block"
+	"kv-engine/internal/config"
+	"kv-engine/internal/fileutil"
+	"kv-engine/internal/merkle"
 	"kv-engine/internal/model"
+	"kv-engine/internal/probabilistic/blooms/bloom"
 )
 
+// Ekstenzije fajlova jednog SSTable-a. Svi fajlovi jedne tabele dele istu
+// osnovu imena: sst_<broj> (starije tabele sst_<nanos>). U single-file rezimu
+// tabela je samo .sst fajl, a u multi-file rezimu .meta cuva stvarne duzine
+// delova.
+const (
+	sstExt = ".sst"
+
+	metaExt    = ".meta"
+	dataExt    = ".data"
+	indexExt   = ".index"
+	summaryExt = ".summary"
+	filterExt  = ".filter"
+	merkleExt  = ".merkle"
+)
+
+// Sav I/O tabela ide kroz BlockManager: fajlovi se pisu u blokovima od
+// blockSize bajtova (svaki deo tabele pocinje na granici bloka), a citaju
+// blok po blok preko njegovog cache-a.
 type Manager struct {
-	dir              string
+	bm               *block.BlockManager
+	dir              string // sstable direktorijum; nivoi su njegovi poddirektorijumi
 	multiFileSSTable bool
+	summaryStep      int     // svaki summaryStep-ti kljuc iz index-a ide u summary
+	bloomFPRate      float64 // zeljena verovatnoca lazno pozitivnih za bloom filter
+	blockSize        int     // velicina bloka za I/O i za merkle stablo
+
+	maxLevels    int
+	compactor    Compactor // strategija kompakcije (vidi compaction.go)
+	compactStats compactionCounters
+
+	// tabele po nivoima. Tabela ulazi u nivo tek kada su svi njeni fajlovi
+	// upisani, i to zajedno sa upisom izmene u manifest (vidi manifest.go).
+	mu       sync.RWMutex
+	levels   [][]*table
+	manifest *manifestLog
+
+	// broj za ime sledece tabele; cuva se u manifest-u
+	nextFile atomic.Uint64
+
+	// ucitani summary-ji po tabeli, da se pri svakom Get-u ne citaju sa diska
+	summaryMu sync.Mutex
+	summaries map[string]*summary
+
+	// ucitani bloom filteri po tabeli i brojaci njihovog rada
+	filterMu    sync.Mutex
+	filters     map[string]*bloom.BloomFilter
+	filterStats filterCounters
 }
 
-func New(dir string, multiFileSSTable bool) *Manager {
-	return &Manager{dir: dir, multiFileSSTable: multiFileSSTable}
+func New(dir string, cfg config.Config, bm *block.BlockManager) (*Manager, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	m := &Manager{
+		bm:               bm,
+		dir:              dir,
+		multiFileSSTable: cfg.MultiFileSSTable,
+		summaryStep:      cfg.SummaryStep,
+		bloomFPRate:      cfg.BloomFalsePositiveRate,
+		blockSize:        cfg.BlockSize,
+		maxLevels:        cfg.MaxLevels,
+		compactor:        newCompactor(cfg),
+		summaries:        make(map[string]*summary),
+		filters:          make(map[string]*bloom.BloomFilter),
+	}
+
+	if err := m.loadLevels(); err != nil {
+		if m.manifest != nil {
+			m.manifest.close()
+		}
+		return nil, err
+	}
+	return m, nil
 }
 
-// [keyLen uvarint][valLen uvarint][tomb u8][seq uvarint][key][val]
+// Close zatvara manifest. Flush i kompakcija posle Close-a vracaju gresku.
+func (m *Manager) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.manifest.close()
+}
 
+// Format zapisa u data delu (verzija 2):
+//
+//	[keyLen uvarint][valLen uvarint][tomb u8][seq uvarint][expiresAt uvarint][key][val]
+//
+// Verzija 1 nema expiresAt; takve tabele se i dalje citaju (zapisi bez TTL-a).
 func encodeRecord(r model.Record) []byte {
 	key := []byte(r.Key)
 	val := r.Value
@@ -34,7 +117,7 @@ func encodeRecord(r model.Record) []byte {
 	// varint maksimalno 10 bajtova za u64
 	tmp := make([]byte, 10)
 
-	buf := make([]byte, 0, 10+10+1+10+len(key)+len(val))
+	buf := make([]byte, 0, 10+10+1+10+10+len(key)+len(val))
 
 	// keyLen
 	n := binary.PutUvarint(tmp, uint64(len(key)))
@@ -55,6 +138,10 @@ func encodeRecord(r model.Record) []byte {
 	n = binary.PutUvarint(tmp, r.Seq)
 	buf = append(buf, tmp[:n]...)
 
+	// expiresAt (0 = bez TTL-a)
+	n = binary.PutUvarint(tmp, r.ExpiresAt)
+	buf = append(buf, tmp[:n]...)
+
 	// key + val
 	buf = append(buf, key...)
 	buf = append(buf, val...)
@@ -62,141 +149,454 @@ func encodeRecord(r model.Record) []byte {
 	return buf
 }
 
+// Flush upisuje zapise iz memtable-a kao novu L0 tabelu.
 func (m *Manager) Flush(records []model.Record) error {
-	if m.multiFileSSTable {
-		err := m.Flush_Data(records)
-		err = m.Flush_Index(records)
-		err = m.Flush_Summary(records)
-		err = m.Flush_Filter(records)
-		err = m.Flush_Merkle(records)
-		if err != nil {
-			return err
-		}
+	if len(records) == 0 {
 		return nil
-	} else {
-		err := m.Flush_SingleFile(records)
+	}
+	// kljuc rastuce, verzije istog kljuca od novije ka starijoj
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].Key != records[j].Key {
+			return records[i].Key < records[j].Key
+		}
+		return records[i].Seq > records[j].Seq
+	})
+
+	t, err := m.writeTable(0, records)
+	if err != nil {
 		return err
 	}
-}
 
-func (m *Manager) Flush_SingleFile(records []model.Record) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.levels[0] = append(m.levels[0], t)
+	if err := m.logEdit(addEdit(t)); err != nil {
+		m.levels[0] = m.levels[0][:len(m.levels[0])-1]
+		m.unref(t)
+		return err
+	}
 	return nil
 }
 
-func (m *Manager) Flush_Data(records []model.Record) error {
-	if err := os.MkdirAll(m.dir, 0755); err != nil {
-		return err
+// writeTable upisuje sortirane zapise kao novu tabelu na zadatom nivou.
+// Tabela jos nije ni u jednom nivou; to radi pozivalac, uz upis manifest-a.
+func (m *Manager) writeTable(level int, records []model.Record) (*table, error) {
+	dir := m.levelDir(level)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
 	}
+	base := filepath.Join(dir, fmt.Sprintf("sst_%06d", m.nextFile.Add(1)))
 
-	name := fmt.Sprintf("sst_%d.data", time.Now().UnixNano())
-	path := filepath.Join(m.dir, name)
+	p, err := m.encodeTable(records)
+	if err != nil {
+		return nil, err
+	}
 
-	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	var t *table
+	if m.multiFileSSTable {
+		t, err = m.Flush_MultiFile(base, p)
+	} else {
+		t, err = m.Flush_SingleFile(base, p)
+	}
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer f.Close()
 
-	sort.Slice(records, func(i, j int) bool {
-		return records[i].Key < records[j].Key
-	})
+	t.level = level
+	t.refs.Store(1)
+	t.minKey, t.maxKey = records[0].Key, records[len(records)-1].Key
+	t.minSeq, t.maxSeq = records[0].Seq, records[0].Seq
+	for _, r := range records {
+		t.minSeq = min(t.minSeq, r.Seq)
+		t.maxSeq = max(t.maxSeq, r.Seq)
+	}
+	return t, nil
+}
 
+// parts su kodirani delovi jedne tabele; isti su za oba formata, razlikuje se
+// samo kako se rasporede po fajlovima.
+type parts struct {
+	data    []byte
+	index   []byte
+	summary []byte
+	filter  []byte
+	merkle  []byte
+}
+
+func (m *Manager) encodeTable(records []model.Record) (*parts, error) {
+	p := &parts{}
+
+	// data: zapisi redom, uz index entry (kljuc -> offset) za svaki
+	index := make([]model.IndexEntry, 0, len(records))
 	for _, r := range records {
-		b := encodeRecord(r)
-		if _, err := f.Write(b); err != nil {
-			return err
+		index = append(index, model.IndexEntry{Key: r.Key, DataOffset: uint64(len(p.data))})
+		p.data = append(p.data, encodeRecord(r)...)
+	}
+
+	// index: [keyLen uvarint][key][dataOffset uvarint] za svaki zapis,
+	// uz poziciju svakog kljuca u index-u za summary
+	positions := make([]model.SummaryEntry, 0, len(index))
+	for _, e := range index {
+		positions = append(positions, model.SummaryEntry{Key: e.Key, IndexOffset: uint64(len(p.index))})
+		p.index = append(p.index, encodeIndexEntry(e)...)
+	}
+
+	// summary: granice tabele i svaki summaryStep-ti kljuc iz index-a
+	if len(positions) > 0 {
+		s := &summary{
+			firstKey: positions[0].Key,
+			lastKey:  positions[len(positions)-1].Key,
 		}
+		for i := 0; i < len(positions); i += m.summaryStep {
+			s.entries = append(s.entries, positions[i])
+		}
+		p.summary = encodeSummary(s)
 	}
-	if err := f.Sync(); err != nil {
-		return err
+
+	// filter: bloom filter nad kljucevima tabele
+	if len(records) > 0 {
+		bf := bloom.NewBloomFilter(len(records), m.bloomFPRate)
+		for _, r := range records {
+			bf.Add([]byte(r.Key))
+		}
+		data, err := bf.Serialize()
+		if err != nil {
+			return nil, err
+		}
+		p.filter = data
 	}
 
-	return nil
-}
+	// merkle: stablo nad blokovima data dela
+	leaves, err := blockHashes(bytes.NewReader(p.data), m.blockSize)
+	if err != nil {
+		return nil, err
+	}
+	p.merkle = merkle.Serialize(merkle.Build(leaves, m.blockSize))
 
-func (m *Manager) Flush_Index(records []model.Record) error {
-	return nil
+	return p, nil
 }
 
-func (m *Manager) Flush_Summary(records []model.Record) error {
-	return nil
+// Flush_MultiFile upisuje svaki deo tabele u svoj fajl (.data, .index, .summary,
+// .filter, .merkle), dopunjen do pune velicine bloka. Prazni delovi se ne
+// upisuju. Prvo ide .meta sa stvarnim duzinama delova.
+func (m *Manager) Flush_MultiFile(base string, p *parts) (*table, error) {
+	ft := model.SSTFooter{
+		DataLen:    uint64(len(p.data)),
+		IndexLen:   uint64(len(p.index)),
+		SummaryLen: uint64(len(p.summary)),
+		FilterLen:  uint64(len(p.filter)),
+		MerkleLen:  uint64(len(p.merkle)),
+		Version:    formatVersion,
+		Magic:      footerMagic,
+	}
+	files := []tableFile{{metaExt, encodeFooter(ft)}}
+
+	for _, f := range []tableFile{
+		{dataExt, p.data},
+		{indexExt, p.index},
+		{summaryExt, p.summary},
+		{filterExt, p.filter},
+		{merkleExt, p.merkle},
+	} {
+		if len(f.data) == 0 && f.ext != dataExt {
+			continue
+		}
+		files = append(files, f)
+	}
+	if err := m.writeFiles(base, files); err != nil {
+		return nil, err
+	}
+	return m.openMultiFile(base)
 }
 
-func (m *Manager) Flush_Filter(records []model.Record) error {
-	return nil
-}
+// Flush_SingleFile upisuje sve delove tabele jedan za drugim u .sst fajl,
+// svaki od granice bloka, a footer sa offsetom i duzinom svake sekcije na
+// sam kraj poslednjeg bloka.
+func (m *Manager) Flush_SingleFile(base string, p *parts) (*table, error) {
+	bs := m.blockSize
+	pad := func(buf []byte, n int) []byte {
+		return append(buf, make([]byte, (bs-n%bs)%bs)...)
+	}
 
-func (m *Manager) Flush_Merkle(records []model.Record) error {
-	return nil
+	ft := model.SSTFooter{DataLen: uint64(len(p.data))}
+	buf := pad(p.data, len(p.data))
+	for _, s := range []struct {
+		data []byte
+		off  *uint64
+		len  *uint64
+	}{
+		{p.index, &ft.IndexOffset, &ft.IndexLen},
+		{p.summary, &ft.SummaryOffset, &ft.SummaryLen},
+		{p.filter, &ft.FilterOffset, &ft.FilterLen},
+		{p.merkle, &ft.MerkleOffset, &ft.MerkleLen},
+	} {
+		*s.off, *s.len = uint64(len(buf)), uint64(len(s.data))
+		buf = pad(append(buf, s.data...), len(s.data))
+	}
+
+	// footer zavrsava poslednji blok; ako ne staje iza merkle-a, dobija svoj
+	buf = buf[:ft.MerkleOffset+ft.MerkleLen]
+	buf = pad(buf, len(buf)+footerSize)
+	ft.Version = formatVersion
+	ft.Magic = footerMagic
+	buf = append(buf, encodeFooter(ft)...)
+
+	if err := m.writeFiles(base, []tableFile{{sstExt, buf}}); err != nil {
+		return nil, err
+	}
+	return m.openSingleFile(base)
 }
 
-func (m *Manager) Get(key string) model.GetResult {
-	files, _ := filepath.Glob(filepath.Join(m.dir, "*.data"))
-	sort.Strings(files)
-	// najnoviji fajlovi prvo
-	for i := len(files) - 1; i >= 0; i-- {
-		if res, ok := scanFile(files[i], key); ok {
-			return res
+// Get trazi najnoviju verziju kljuca sa seq <= maxSeq, od novijih podataka
+// ka starijim: L0 od najnovije tabele, pa nivo po nivo.
+func (m *Manager) Get(key string, maxSeq uint64) (model.GetResult, error) {
+	tables := m.acquire(key)
+	defer m.release(tables)
+
+	for _, t := range tables {
+		rec, ok, err := m.getFromTable(t, key, maxSeq)
+		if err != nil {
+			return model.GetResult{}, fmt.Errorf("%s: %w", t.id(), err)
+		}
+		if ok {
+			return model.GetResult{
+				Key:       rec.Key,
+				Value:     rec.Value,
+				Found:     true,
+				Tombstone: rec.Tombstone,
+				Seq:       rec.Seq,
+				ExpiresAt: rec.ExpiresAt,
+			}, nil
 		}
 	}
 
-	return model.GetResult{Found: false}
+	return model.GetResult{Found: false}, nil
 }
 
-// [keyLen uvarint][valLen uvarint][tomb u8][seq uvarint][key][val]
+// MaxSeq vraca najveci seq zapisan u bilo kom SSTable-u.
+func (m *Manager) MaxSeq() uint64 {
+	tables := m.acquireAll()
+	defer m.release(tables)
 
-func scanFile(path string, key string) (model.GetResult, bool) {
-	f, err := os.Open(path)
+	var maxSeq uint64
+	for _, t := range tables {
+		maxSeq = max(maxSeq, t.maxSeq)
+	}
+	return maxSeq
+}
+
+// getFromTable preko summary-ja preskace tabele cije granice ne pokrivaju
+// kljuc, pa cita samo jedan deo index-a i verzije kljuca iz data sekcije, od
+// najnovije, dok ne naidje na onu sa seq <= maxSeq. Tabele bez summary/index
+// dela (starije verzije) se citaju sporije.
+func (m *Manager) getFromTable(t *table, key string, maxSeq uint64) (model.Record, bool, error) {
+	var start, end int64 = 0, -1
+
+	sum, err := m.loadSummary(t)
 	if err != nil {
-		return model.GetResult{}, false
+		return model.Record{}, false, err
+	}
+	if sum != nil {
+		if key < sum.firstKey || key > sum.lastKey {
+			return model.Record{}, false, nil
+		}
+		start, end = sum.indexRange(key)
 	}
-	defer f.Close()
 
-	r := bufio.NewReader(f)
+	bf, err := m.loadFilter(t)
+	if err != nil {
+		return model.Record{}, false, err
+	}
+	if bf != nil && !bf.MightContain([]byte(key)) {
+		m.filterStats.misses.Add(1)
+		return model.Record{}, false, nil
+	}
 
-	for {
-		keyLen, err := binary.ReadUvarint(r)
-		if err != nil {
-			if err == io.EOF {
-				return model.GetResult{}, false
-			}
-			return model.GetResult{}, false
+	if !t.index.exists() {
+		return m.scanTable(t, key, maxSeq)
+	}
+	offs, err := findInIndex(m.reader(t.index, start, end), key)
+	if err != nil {
+		return model.Record{}, false, err
+	}
+	if bf != nil {
+		if len(offs) > 0 {
+			m.filterStats.hits.Add(1)
+		} else {
+			m.filterStats.falsePositives.Add(1)
 		}
+	}
 
-		valLen, err := binary.ReadUvarint(r)
+	for _, off := range offs {
+		rec, err := m.readRecordAt(t, off)
 		if err != nil {
-			return model.GetResult{}, false
+			return model.Record{}, false, err
+		}
+		if rec.Key != key {
+			return model.Record{}, false, fmt.Errorf("index points to key %q instead of %q", rec.Key, key)
 		}
+		if rec.Seq <= maxSeq {
+			return rec, true, nil
+		}
+	}
+	return model.Record{}, false, nil
+}
 
-		tombByte, err := r.ReadByte()
-		if err != nil {
-			return model.GetResult{}, false
+func (m *Manager) scanTable(t *table, key string, maxSeq uint64) (model.Record, bool, error) {
+	var res model.Record
+	found := false
+	err := m.readRecords(t, func(r model.Record) bool {
+		if r.Key != key || r.Seq > maxSeq {
+			return true
 		}
-		tomb := tombByte == 1
+		res = r
+		found = true
+		return false
+	})
+	return res, found, err
+}
 
-		seq, err := binary.ReadUvarint(r)
+// readRecordAt cita jedan zapis sa zadatog offseta u data delu tabele.
+func (m *Manager) readRecordAt(t *table, offset uint64) (model.Record, error) {
+	rec, err := decodeRecord(m.reader(t.data, int64(offset), -1), t.version)
+	return rec, unexpectedEOF(err)
+}
+
+// tableFile je sadrzaj jednog fajla tabele.
+type tableFile struct {
+	ext  string
+	data []byte
+}
+
+// tmpExt se dodaje na ime fajla tabele dok se pise. Fajl dobija pravo ime
+// tek kada su svi fajlovi tabele upisani i fsync-ovani, pa posle pada na disku
+// nema nedovrsene tabele pod pravim imenom; zaostali .tmp fajlovi se brisu
+// pri startu kao i ostala sirocad.
+const tmpExt = ".tmp"
+
+// writeFiles atomski pravi fajlove jedne tabele: sve upise pod privremenim
+// imenima, preimenuje ih i radi fsync direktorijuma. Ako ne uspe, brise sve
+// sto je stigao da napravi.
+func (m *Manager) writeFiles(base string, files []tableFile) (err error) {
+	var written []string
+	defer func() {
 		if err != nil {
-			return model.GetResult{}, false
+			for _, path := range written {
+				os.Remove(path)
+			}
 		}
+	}()
+
+	for _, f := range files {
+		tmp := base + f.ext + tmpExt
+		written = append(written, tmp)
+		if err := m.writeBlocks(tmp, f.data); err != nil {
+			return err
+		}
+	}
+	for _, f := range files {
+		if err := m.bm.Rename(base+f.ext+tmpExt, base+f.ext); err != nil {
+			return err
+		}
+		written = append(written, base+f.ext)
+	}
+	return fileutil.SyncDir(filepath.Dir(base))
+}
+
+// writeBlocks pravi fajl od podataka isecenih na blokove (poslednji se dopunjuje
+// nulama) kroz jedan BlockWriter. Upisani blokovi ne ulaze u cache, isto kao
+// ni blokovi koje kompakcija cita, pa flush i kompakcija ne izbacuju iz
+// cache-a blokove koje citaju Get-ovi. Pre povratka radi fsync.
+func (m *Manager) writeBlocks(path string, data []byte) error {
+	w, err := m.bm.Create(path, m.blockSize)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
 
-		kb := make([]byte, keyLen)
-		if _, err := io.ReadFull(r, kb); err != nil {
-			return model.GetResult{}, false
+	page := make([]byte, m.blockSize)
+	for off := 0; off < len(data); off += m.blockSize {
+		clear(page)
+		copy(page, data[off:])
+		if _, err := w.Append(page); err != nil {
+			return err
 		}
+	}
+	return w.Sync()
+}
 
-		vb := make([]byte, valLen)
-		if _, err := io.ReadFull(r, vb); err != nil {
-			return model.GetResult{}, false
+// readRecords redom dekodira zapise iz data dela tabele i zove fn za svaki,
+// dok fn ne vrati false.
+func (m *Manager) readRecords(t *table, fn func(model.Record) bool) error {
+	r := m.reader(t.data, 0, -1)
+	for {
+		rec, err := decodeRecord(r, t.version)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
 		}
+		if !fn(rec) {
+			return nil
+		}
+	}
+}
 
-		if string(kb) == key {
-			return model.GetResult{
-				Key:       string(kb),
-				Value:     vb,
-				Found:     true,
-				Tombstone: tomb,
-				Seq:       seq,
-			}, true
+// decodeRecord cita zapis u formatu zadate verzije tabele. Vraca io.EOF samo
+// kada je reader tacno na kraju sekcije.
+func decodeRecord(r *sectionReader, version uint32) (model.Record, error) {
+	keyLen, err := binary.ReadUvarint(r)
+	if err != nil {
+		return model.Record{}, err
+	}
+
+	valLen, err := binary.ReadUvarint(r)
+	if err != nil {
+		return model.Record{}, unexpectedEOF(err)
+	}
+
+	tombByte, err := r.ReadByte()
+	if err != nil {
+		return model.Record{}, unexpectedEOF(err)
+	}
+
+	seq, err := binary.ReadUvarint(r)
+	if err != nil {
+		return model.Record{}, unexpectedEOF(err)
+	}
+
+	var expiresAt uint64
+	if version >= 2 {
+		if expiresAt, err = binary.ReadUvarint(r); err != nil {
+			return model.Record{}, unexpectedEOF(err)
 		}
 	}
+
+	kb, err := r.readN(keyLen)
+	if err != nil {
+		return model.Record{}, err
+	}
+
+	vb, err := r.readN(valLen)
+	if err != nil {
+		return model.Record{}, err
+	}
+
+	return model.Record{
+		Key:       string(kb),
+		Value:     vb,
+		Tombstone: tombByte == 1,
+		Seq:       seq,
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// unexpectedEOF: EOF usred zapisa znaci da je fajl odsecen.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
 }
@@ -0,0 +1,141 @@
+package sstable
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+
+	"kv-engine/internal/model"
+)
+
+// summary je proredjen index: granice tabele i svaki N-ti kljuc iz index-a.
+//
+// Format summary dela:
+//
+//	[firstKeyLen uvarint][firstKey][lastKeyLen uvarint][lastKey][count uvarint]
+//	count x [keyLen uvarint][key][indexOffset uvarint]
+type summary struct {
+	firstKey string
+	lastKey  string
+	entries  []model.SummaryEntry
+}
+
+// indexRange vraca deo index-a [start, end) u kom moraju biti sve verzije
+// kljuca ako postoje (end = -1 znaci do kraja index-a). Verzije istog kljuca
+// mogu da predju preko vise summary entry-ja.
+func (s *summary) indexRange(key string) (int64, int64) {
+	// poslednji summary entry ciji je kljuc < key: trazeni deo pocinje od njega
+	i := sort.Search(len(s.entries), func(i int) bool { return s.entries[i].Key >= key })
+	var start int64
+	if i > 0 {
+		start = int64(s.entries[i-1].IndexOffset)
+	}
+	// prvi summary entry ciji je kljuc > key: tu se zavrsava
+	j := sort.Search(len(s.entries), func(j int) bool { return s.entries[j].Key > key })
+	if j == len(s.entries) {
+		return start, -1
+	}
+	return start, int64(s.entries[j].IndexOffset)
+}
+
+func encodeSummary(s *summary) []byte {
+	tmp := make([]byte, 10)
+	var buf []byte
+
+	putString := func(v string) {
+		n := binary.PutUvarint(tmp, uint64(len(v)))
+		buf = append(buf, tmp[:n]...)
+		buf = append(buf, v...)
+	}
+	putUint := func(v uint64) {
+		n := binary.PutUvarint(tmp, v)
+		buf = append(buf, tmp[:n]...)
+	}
+
+	putString(s.firstKey)
+	putString(s.lastKey)
+	putUint(uint64(len(s.entries)))
+	for _, e := range s.entries {
+		putString(e.Key)
+		putUint(e.IndexOffset)
+	}
+	return buf
+}
+
+func decodeSummary(r *sectionReader) (*summary, error) {
+	readString := func() (string, error) {
+		n, err := binary.ReadUvarint(r)
+		if err != nil {
+			return "", unexpectedEOF(err)
+		}
+		b, err := r.readN(n)
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	}
+
+	s := &summary{}
+	var err error
+	if s.firstKey, err = readString(); err != nil {
+		return nil, err
+	}
+	if s.lastKey, err = readString(); err != nil {
+		return nil, err
+	}
+
+	count, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	// svaki entry ima bar dva bajta
+	if count > uint64(r.left)/2 {
+		return nil, fmt.Errorf("%w: summary count %d exceeds section", errCorrupted, count)
+	}
+	s.entries = make([]model.SummaryEntry, 0, count)
+	for i := uint64(0); i < count; i++ {
+		key, err := readString()
+		if err != nil {
+			return nil, err
+		}
+		off, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		s.entries = append(s.entries, model.SummaryEntry{Key: key, IndexOffset: off})
+	}
+	return s, nil
+}
+
+// loadSummary vraca summary tabele iz cache-a ili ga cita sa diska.
+// Tabele bez summary dela vracaju nil. Kao i kod loadFilter, citanje sa
+// diska ide van summaryMu.
+func (m *Manager) loadSummary(t *table) (*summary, error) {
+	if !t.summary.exists() {
+		return nil, nil
+	}
+
+	m.summaryMu.Lock()
+	s, ok := m.summaries[t.name]
+	m.summaryMu.Unlock()
+	if ok {
+		return s, nil
+	}
+
+	data, err := m.readAll(t.summary)
+	if err != nil {
+		return nil, err
+	}
+	if s, err = decodeSummary(newSectionReader(bytes.NewReader(data), int64(len(data)))); err != nil {
+		return nil, err
+	}
+
+	m.summaryMu.Lock()
+	defer m.summaryMu.Unlock()
+	if cur, ok := m.summaries[t.name]; ok {
+		return cur, nil
+	}
+	m.summaries[t.name] = s
+	return s, nil
+}
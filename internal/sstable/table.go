@@ -0,0 +1,293 @@
+package sstable
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+
+	"kv-engine/internal/block"
+)
+
+// section je deo fajla u kom lezi jedan deo tabele (data, index, ...).
+// U multi-file rezimu sekcija je pocetak svog fajla, u single-file rezimu deo
+// .sst fajla. Sekcija sa praznom putanjom ne postoji (npr. tabela starije
+// verzije bez summary-ja).
+type section struct {
+	path string
+	off  int64
+	len  int64
+}
+
+func (s section) exists() bool {
+	return s.path != ""
+}
+
+// table opisuje jednu SSTable-u na disku, nezavisno od formata.
+type table struct {
+	name    string // putanja bez ekstenzije (dir/levelN/sst_<broj>)
+	level   int
+	version uint32 // verzija formata zapisa
+	// legacy: tabela bez footer-a i .meta fajla, pa joj data deo moze
+	// zavrsavati odsecenim zapisom (prekinut flush)
+	legacy bool
+
+	// granice kljuceva i seq-ova i velicina data dela, za izbor tabela
+	// pri citanju i kompakciji
+	minKey, maxKey string
+	minSeq, maxSeq uint64
+
+	// jednu referencu drzi Manager dok je tabela ziva, a po jednu svaki
+	// citac; fajlovi se brisu tek kada referenci vise nema
+	refs atomic.Int32
+
+	data    section
+	index   section
+	summary section
+	filter  section
+	merkle  section
+}
+
+func (t *table) id() string {
+	return filepath.Base(t.name)
+}
+
+func (t *table) size() int64 {
+	return t.data.len
+}
+
+func (t *table) covers(key string) bool {
+	return t.minKey <= key && key <= t.maxKey
+}
+
+func (t *table) overlaps(minKey, maxKey string) bool {
+	return t.minKey <= maxKey && minKey <= t.maxKey
+}
+
+// files vraca sve fajlove tabele koji postoje na disku.
+func (t *table) files() []string {
+	var out []string
+	for _, ext := range []string{sstExt, metaExt, dataExt, indexExt, summaryExt, filterExt, merkleExt} {
+		if _, err := os.Stat(t.name + ext); err == nil {
+			out = append(out, t.name+ext)
+		}
+	}
+	return out
+}
+
+// blockReader cita deo sekcije blok po blok preko BlockManager-a, pa se
+// cesto citani blokovi sluze iz cache-a. Zapis koji prelazi granicu bloka
+// se prosto nastavlja u sledecem bloku.
+type blockReader struct {
+	bm        *block.BlockManager
+	path      string
+	blockSize int64
+	pos, end  int64 // apsolutni offseti u fajlu
+}
+
+func (r *blockReader) Read(p []byte) (int, error) {
+	if r.pos >= r.end {
+		return 0, io.EOF
+	}
+
+	b, err := r.bm.ReadBlock(r.path, uint64(r.pos/r.blockSize), int(r.blockSize))
+	if err != nil {
+		return 0, err
+	}
+	from := r.pos % r.blockSize
+	to := min(r.blockSize, from+r.end-r.pos)
+	n := copy(p, b[from:to])
+	r.pos += int64(n)
+	return n, nil
+}
+
+// open vraca reader za deo sekcije [from, to); to = -1 znaci do kraja sekcije.
+func (m *Manager) open(s section, from, to int64) io.Reader {
+	if to < 0 || to > s.len {
+		to = s.len
+	}
+	if from > to {
+		from = to
+	}
+	return &blockReader{
+		bm:        m.bm,
+		path:      s.path,
+		blockSize: int64(m.blockSize),
+		pos:       s.off + from,
+		end:       s.off + to,
+	}
+}
+
+// errCorrupted: sadrzaj tabele se ne moze dekodirati.
+var errCorrupted = errors.New("sstable: corrupted data")
+
+// sectionReader cita deo sekcije i zna koliko je bajtova ostalo do njegovog
+// kraja, da ostecena duzina procitana sa diska ne bi izazvala ogromnu
+// alokaciju. Uvarint-ovi se citaju preko ReadByte, a nizovi bajtova preko
+// readN.
+type sectionReader struct {
+	r    *bufio.Reader
+	left int64
+}
+
+func newSectionReader(r io.Reader, size int64) *sectionReader {
+	return &sectionReader{r: bufio.NewReader(r), left: size}
+}
+
+func (r *sectionReader) ReadByte() (byte, error) {
+	b, err := r.r.ReadByte()
+	if err == nil {
+		r.left--
+	}
+	return b, err
+}
+
+// readN cita tacno n bajtova. Duzina veca od ostatka dela je ostecenje, a
+// za tabele bez footer-a i odsecen rep, pa greska vazi i kao
+// io.ErrUnexpectedEOF.
+func (r *sectionReader) readN(n uint64) ([]byte, error) {
+	if n > uint64(max(r.left, 0)) {
+		return nil, fmt.Errorf("%w: %w: length %d exceeds remaining %d bytes", errCorrupted, io.ErrUnexpectedEOF, n, r.left)
+	}
+	b := make([]byte, n)
+	k, err := io.ReadFull(r.r, b)
+	r.left -= int64(k)
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	return b, nil
+}
+
+// reader je open za dekodiranje: broji bajtove do kraja dela [from, to).
+func (m *Manager) reader(s section, from, to int64) *sectionReader {
+	if to < 0 || to > s.len {
+		to = s.len
+	}
+	from = min(from, to)
+	return newSectionReader(m.open(s, from, to), to-from)
+}
+
+// readAll cita celu sekciju.
+func (m *Manager) readAll(s section) ([]byte, error) {
+	buf := make([]byte, s.len)
+	if _, err := io.ReadFull(m.open(s, 0, -1), buf); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	return buf, nil
+}
+
+// openTable otvara tabelu bilo kog formata: .sst je single-file tabela,
+// .data multi-file. Oba formata mogu da postoje jedan pored drugog.
+func (m *Manager) openTable(name string, level int) (*table, error) {
+	var t *table
+	var err error
+	if _, err = os.Stat(name + sstExt); err == nil {
+		t, err = m.openSingleFile(name)
+	} else if os.IsNotExist(err) {
+		t, err = m.openMultiFile(name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	t.level = level
+	t.refs.Store(1)
+	return t, nil
+}
+
+// openSingleFile cita footer sa kraja .sst fajla i iz njega pravi sekcije.
+func (m *Manager) openSingleFile(name string) (*table, error) {
+	path := name + sstExt
+	st, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	size := st.Size()
+	if size < footerSize {
+		return nil, errBadFooter
+	}
+
+	buf, err := m.bm.ReadAt(path, size-footerSize, footerSize)
+	if err != nil {
+		return nil, err
+	}
+	ft, err := decodeFooter(buf)
+	if err != nil {
+		return nil, err
+	}
+	if !fits(ft, uint64(size-footerSize)) {
+		return nil, errBadFooter
+	}
+
+	sec := func(off, n uint64) section {
+		if n == 0 {
+			return section{}
+		}
+		return section{path: path, off: int64(off), len: int64(n)}
+	}
+	return &table{
+		name:    name,
+		version: ft.Version,
+		data:    section{path: path, off: 0, len: int64(ft.DataLen)},
+		index:   sec(ft.IndexOffset, ft.IndexLen),
+		summary: sec(ft.SummaryOffset, ft.SummaryLen),
+		filter:  sec(ft.FilterOffset, ft.FilterLen),
+		merkle:  sec(ft.MerkleOffset, ft.MerkleLen),
+	}, nil
+}
+
+// openMultiFile: svaki deo tabele je svoj fajl, dopunjen do pune velicine
+// bloka, a stvarne duzine su u .meta fajlu. .meta se upisuje prvi, pa fajl
+// kraci od zapisane duzine znaci prekinut flush. Tabele starijih verzija nemaju
+// .meta (ni dopunu), pa im je duzina dela velicina fajla; delovi koji ne
+// postoje ostaju prazne sekcije.
+func (m *Manager) openMultiFile(name string) (*table, error) {
+	// tabele bez .meta fajla su pisane pre verzionisanja, u formatu 1
+	t := &table{name: name, version: 1}
+	secs := []*section{&t.data, &t.index, &t.summary, &t.filter, &t.merkle}
+	exts := []string{dataExt, indexExt, summaryExt, filterExt, merkleExt}
+
+	// lens ostaje nil za tabele bez .meta fajla
+	var lens []uint64
+	if _, err := os.Stat(name + metaExt); err == nil {
+		meta, err := m.bm.ReadAt(name+metaExt, 0, footerSize)
+		if err != nil {
+			// .meta kraci od footer-a: flush je prekinut odmah na pocetku
+			return nil, errBadFooter
+		}
+		ft, err := decodeFooter(meta)
+		if err != nil {
+			return nil, err
+		}
+		t.version = ft.Version
+		lens = []uint64{ft.DataLen, ft.IndexLen, ft.SummaryLen, ft.FilterLen, ft.MerkleLen}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	t.legacy = lens == nil
+
+	for i, ext := range exts {
+		st, err := os.Stat(name + ext)
+		if os.IsNotExist(err) {
+			if lens != nil && lens[i] > 0 {
+				return nil, errBadFooter
+			}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		n := st.Size()
+		if lens != nil {
+			if uint64(n) < lens[i] {
+				return nil, errBadFooter
+			}
+			n = int64(lens[i])
+		}
+		*secs[i] = section{path: name + ext, off: 0, len: n}
+	}
+	return t, nil
+}
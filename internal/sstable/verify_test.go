@@ -0,0 +1,56 @@
+package sstable
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"kv-engine/internal/block"
+	"kv-engine/internal/config"
+	"kv-engine/internal/model"
+)
+
+func TestVerifyUsesStoredLeafSize(t *testing.T) {
+	dir := t.TempDir()
+	m := openTestManager(t, dir)
+	var recs []model.Record
+	for i := 0; i < 300; i++ {
+		recs = append(recs, model.Record{Key: fmt.Sprintf("k%04d", i), Value: []byte(strings.Repeat("v", 50)), Seq: uint64(i + 1)})
+	}
+	if err := m.Flush(recs); err != nil {
+		t.Fatal(err)
+	}
+	data := m.levels[0][0].data
+	m.Close()
+	if data.len <= 2*4096 {
+		t.Fatalf("data is only %d bytes", data.len)
+	}
+
+	// block_size promenjen posle upisa tabele
+	cfg := config.Default()
+	cfg.BlockSize = 8192
+	m, err := New(dir, cfg, block.NewBlockManager(64))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := m.Verify()
+	if len(res) != 1 || !res[0].OK() {
+		t.Fatalf("Verify = %+v, want OK", res)
+	}
+	m.Close()
+
+	b, _ := os.ReadFile(data.path)
+	b[data.off+2*4096+10] ^= 0x01
+	os.WriteFile(data.path, b, 0644)
+
+	m, err = New(dir, cfg, block.NewBlockManager(64))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	res = m.Verify()
+	if len(res) != 1 || fmt.Sprint(res[0].CorruptedBlocks) != "[2]" {
+		t.Fatalf("Verify = %+v, want block 2 corrupted", res)
+	}
+}
@@ -1,12 +1,713 @@
 package wal
 
-import "kv-engine/internal/model"
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
-// TODO: implementirati segmentirani WAL
-type WAL struct{}
+	"kv-engine/internal/fileutil"
+	"kv-engine/internal/model"
+)
 
-func New() *WAL { return &WAL{} }
+var ErrCorruptedRecord = errors.New("wal: corrupted record")
 
-func (w *WAL) Append(_ model.Record) error { return nil }
+// Format jednog zapisa u segmentu (BigEndian):
+//
+//	[crc u32][kind u8][seq u64][expiresAt u64][keyLen u32][valLen u32][key][val]
+//
+// CRC se racuna nad svim bajtovima posle samog CRC polja.
+//
+// Batch (kind 2) je jedan zapis sa istim header-om: seq je seq prve operacije
+// (ostale dobijaju redom sledece), keyLen je 0, a val su operacije:
+//
+//	[count u32] count x [kind u8][expiresAt u64][keyLen u32][valLen u32][key][val]
+//
+// Posle pada se batch ili vraca ceo ili se odbacuje ceo.
+const (
+	headerSize = 4 + 1 + 8 + 8 + 4 + 4
 
-func (w *WAL) Replay(_ func(model.Record) error) error { return nil }
+	kindPut    byte = 0
+	kindDelete byte = 1
+	kindBatch  byte = 2
+
+	batchOpHeaderSize = 1 + 8 + 4 + 4
+
+	segmentPrefix = "wal_"
+	segmentSuffix = ".log"
+
+	checkpointFile = "checkpoint"
+)
+
+// Rezimi trajnosti WAL-a.
+const (
+	SyncAlways   = "always"   // fsync posle svakog zapisa (uz group commit)
+	SyncInterval = "interval" // fsync u pozadini na svakih syncInterval
+	SyncNone     = "none"     // bez fsync-a, OS odlucuje kada ide na disk
+)
+
+// WAL je segmentirani write-ahead log. Svaki segment drzi najvise
+// segmentMaxRecords zapisa, posle cega se otvara novi fajl.
+// Append, Checkpoint i Close su bezbedni za konkurentno koriscenje.
+type WAL struct {
+	dir               string
+	segmentMaxRecords int
+	syncMode          string
+
+	mu         sync.Mutex
+	file       *os.File // trenutni segment (nil dok se ne upise prvi zapis)
+	segIndex   uint64   // index trenutnog segmenta
+	segRecords int      // broj zapisa u trenutnom segmentu
+	segBytes   int64    // velicina trenutnog segmenta
+
+	// logicke pozicije: broj upisanih zapisa i broj zapisa pokrivenih fsync-om
+	// (batch se broji kao jedan zapis)
+	appended uint64
+	synced   uint64
+
+	// najveci seq po segmentu, popunjava se kroz Replay i Append
+	segMaxSeq map[uint64]uint64
+	// low-water mark: svi zapisi sa seq <= checkpoint su vec u SSTable-ovima
+	checkpoint uint64
+	// tokom replay-a se segmenti ne brisu jer se jos citaju
+	replaying bool
+
+	stop chan struct{} // gasi pozadinski fsync (interval rezim)
+	done chan struct{}
+}
+
+func New(dir string, segmentMaxRecords int, syncMode string, syncInterval time.Duration) (*WAL, error) {
+	if segmentMaxRecords <= 0 {
+		return nil, fmt.Errorf("wal segment max records must be > 0")
+	}
+	switch syncMode {
+	case SyncAlways, SyncNone:
+	case SyncInterval:
+		if syncInterval <= 0 {
+			return nil, fmt.Errorf("wal sync interval must be > 0")
+		}
+	default:
+		return nil, fmt.Errorf("unknown wal sync mode: %q", syncMode)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	segs, err := listSegments(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &WAL{
+		dir:               dir,
+		segmentMaxRecords: segmentMaxRecords,
+		syncMode:          syncMode,
+		segMaxSeq:         make(map[uint64]uint64),
+		checkpoint:        readCheckpoint(filepath.Join(dir, checkpointFile)),
+	}
+	// novi zapisi uvek idu u novi segment, postojeci ostaju samo za replay
+	if len(segs) > 0 {
+		w.segIndex = segs[len(segs)-1]
+	}
+
+	if syncMode == SyncInterval {
+		w.stop = make(chan struct{})
+		w.done = make(chan struct{})
+		go w.syncLoop(syncInterval)
+	}
+	return w, nil
+}
+
+// Append upisuje zapise u trenutni segment. U SyncAlways rezimu vraca tek
+// kada su zapisi na disku; konkurentni pozivi dele isti fsync.
+func (w *WAL) Append(recs ...model.Record) error {
+	groups := make([][]model.Record, len(recs))
+	for i := range recs {
+		groups[i] = recs[i : i+1]
+	}
+	return w.AppendGroups(groups...)
+}
+
+// AppendGroups je Append u kome svaka grupa sa vise zapisa ide kao jedan
+// batch zapis. Zapisi grupe moraju imati uzastopne seq-ove.
+func (w *WAL) AppendGroups(groups ...[]model.Record) error {
+	pos, err := w.write(groups)
+	if err != nil {
+		return err
+	}
+	if w.syncMode == SyncAlways {
+		return w.syncTo(pos)
+	}
+	return nil
+}
+
+// write upisuje grupe u OS bafer (bez fsync-a) i vraca poziciju poslednje.
+// Batch se ne deli izmedju segmenata, pa segment moze imati i vise od
+// segmentMaxRecords zapisa. Ako upis ne uspe, sve sto je ovaj poziv upisao
+// se uklanja, da delimican zapis ne bi ostao usred loga.
+func (w *WAL) write(groups [][]model.Record) (uint64, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	start := walPos{
+		open:     w.file != nil,
+		segIndex: w.segIndex,
+		records:  w.segRecords,
+		bytes:    w.segBytes,
+		maxSeq:   w.segMaxSeq[w.segIndex],
+		appended: w.appended,
+	}
+	for _, g := range groups {
+		if len(g) == 0 {
+			continue
+		}
+		if w.file == nil || w.segRecords >= w.segmentMaxRecords {
+			if err := w.rollover(); err != nil {
+				return 0, w.undoWrite(start, err)
+			}
+		}
+
+		buf := encodeRecord(g[0])
+		if len(g) > 1 {
+			buf = encodeBatch(g)
+		}
+		if _, err := w.file.Write(buf); err != nil {
+			return 0, w.undoWrite(start, err)
+		}
+		w.segRecords += len(g)
+		w.segBytes += int64(len(buf))
+		w.appended++
+		if last := g[len(g)-1].Seq; last > w.segMaxSeq[w.segIndex] {
+			w.segMaxSeq[w.segIndex] = last
+		}
+	}
+	return w.appended, nil
+}
+
+// walPos je stanje WAL-a pre jednog write-a.
+type walPos struct {
+	open     bool // segment segIndex je bio otvoren za upis
+	segIndex uint64
+	records  int
+	bytes    int64
+	maxSeq   uint64
+	appended uint64
+}
+
+// undoWrite vraca WAL na stanje pre neuspelog write-a i vraca err. Bez
+// rollover-a segment se skracuje i ostaje otvoren. Posle rollover-a su svi
+// raniji zapisi vec fsync-ovani, pa se novi segmenti brisu, stari skracuje,
+// a sledeci upis otvara novi segment. Ako ni to ne uspe, segment se
+// zatvara, pa ostatak bar ne ide iza delimicnog zapisa.
+func (w *WAL) undoWrite(start walPos, err error) error {
+	if w.segIndex == start.segIndex && w.file != nil {
+		if terr := w.file.Truncate(start.bytes); terr == nil {
+			w.segRecords, w.segBytes = start.records, start.bytes
+			w.segMaxSeq[w.segIndex] = start.maxSeq
+			w.appended = start.appended
+			return err
+		}
+		// zapisi drugih pisaca pre ovog poziva moraju biti trajni pre zatvaranja
+		if serr := w.syncLocked(); serr != nil {
+			err = errors.Join(err, serr)
+		}
+	}
+
+	if w.file != nil {
+		w.file.Close()
+		w.file = nil
+	}
+	for idx := start.segIndex + 1; idx <= w.segIndex; idx++ {
+		if rerr := os.Remove(w.segmentPath(idx)); rerr != nil && !os.IsNotExist(rerr) {
+			return errors.Join(err, rerr)
+		}
+		delete(w.segMaxSeq, idx)
+	}
+	if start.open && w.segIndex != start.segIndex {
+		if terr := os.Truncate(w.segmentPath(start.segIndex), start.bytes); terr != nil {
+			return errors.Join(err, terr)
+		}
+		w.segMaxSeq[start.segIndex] = start.maxSeq
+	}
+	w.segIndex = start.segIndex
+	w.appended = start.appended
+	w.synced = min(w.synced, w.appended)
+	return err
+}
+
+// syncTo je group commit: ko prvi dobije lock radi jedan fsync za sve sto je
+// do tada upisano, a ostali koje je taj fsync pokrio odmah izlaze.
+func (w *WAL) syncTo(pos uint64) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.synced >= pos {
+		return nil
+	}
+	return w.syncLocked()
+}
+
+func (w *WAL) syncLocked() error {
+	if w.file == nil || w.synced == w.appended {
+		return nil
+	}
+	if err := w.file.Sync(); err != nil {
+		return err
+	}
+	w.synced = w.appended
+	return nil
+}
+
+func (w *WAL) syncLoop(interval time.Duration) {
+	defer close(w.done)
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-t.C:
+			w.mu.Lock()
+			// greska ce se ponoviti i prijaviti na sledecem Close-u
+			_ = w.syncLocked()
+			w.mu.Unlock()
+		}
+	}
+}
+
+// Replay cita sve segmente redom (najstariji prvi) i za svaki zapis zove fn.
+// Zapisi koje pokriva checkpoint se preskacu. Vraca broj vracenih zapisa.
+// Odbacuje se samo poluupisan rep poslednjeg segmenta (prekinut upis): fajl
+// se skracuje da bi sledeci replay prosao cisto. Osteceni zapis bilo gde
+// drugde je greska, jer bi preskakanje ostatka loga izgubilo potvrdjene
+// upise. Poziva se jednom, pre prvog Append-a.
+func (w *WAL) Replay(fn func(model.Record) error) (int, error) {
+	segs, err := listSegments(w.dir)
+	if err != nil {
+		return 0, err
+	}
+
+	w.setReplaying(true)
+	defer w.setReplaying(false)
+
+	total := 0
+	for i, idx := range segs {
+		n, goodOffset, torn, err := w.replaySegment(idx, fn)
+		total += n
+		if err != nil {
+			return total, err
+		}
+		if !torn {
+			continue
+		}
+		if i != len(segs)-1 {
+			return total, fmt.Errorf("segment %d: %w: torn tail before the last segment (%d later segments)",
+				idx, ErrCorruptedRecord, len(segs)-1-i)
+		}
+		if err := os.Truncate(w.segmentPath(idx), goodOffset); err != nil {
+			return total, err
+		}
+	}
+
+	w.setReplaying(false)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return total, w.removeCovered()
+}
+
+func (w *WAL) setReplaying(v bool) {
+	w.mu.Lock()
+	w.replaying = v
+	w.mu.Unlock()
+}
+
+// CheckpointSeq vraca trenutni low-water mark.
+func (w *WAL) CheckpointSeq() uint64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.checkpoint
+}
+
+// Checkpoint trajno belezi da su svi zapisi do seq (ukljucivo) upisani u
+// SSTable-ove i brise zatvorene segmente koji sadrze samo takve zapise.
+func (w *WAL) Checkpoint(seq uint64) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if seq <= w.checkpoint {
+		return nil
+	}
+	if err := writeCheckpoint(w.dir, seq); err != nil {
+		return err
+	}
+	w.checkpoint = seq
+	return w.removeCovered()
+}
+
+// removeCovered brise zatvorene segmente ciji su svi zapisi pokriveni checkpoint-om.
+func (w *WAL) removeCovered() error {
+	if w.replaying {
+		return nil
+	}
+
+	segs, err := listSegments(w.dir)
+	if err != nil {
+		return err
+	}
+	for _, idx := range segs {
+		if w.file != nil && idx == w.segIndex {
+			continue
+		}
+		maxSeq, known := w.segMaxSeq[idx]
+		if !known || maxSeq > w.checkpoint {
+			// segmenti su poredjani po seq, pa iza ovog nema sta da se brise
+			break
+		}
+		if err := os.Remove(w.segmentPath(idx)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		delete(w.segMaxSeq, idx)
+	}
+	return nil
+}
+
+// Close zaustavlja pozadinski fsync, radi poslednji fsync i zatvara segment.
+func (w *WAL) Close() error {
+	if w.stop != nil {
+		close(w.stop)
+		<-w.done
+		w.stop = nil
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+	err := w.syncLocked()
+	if cerr := w.file.Close(); err == nil {
+		err = cerr
+	}
+	w.file = nil
+	return err
+}
+
+// replaySegment vraca broj procitanih zapisa, offset iza poslednjeg ispravnog
+// zapisa i da li je segment zavrsen poluupisanim zapisom (zapis prelazi kraj
+// fajla ili je ostecen poslednji zapis, a iza njega nema nijednog ispravnog
+// zapisa). Svaki drugi osteceni zapis vraca kao gresku.
+func (w *WAL) replaySegment(idx uint64, fn func(model.Record) error) (int, int64, bool, error) {
+	f, err := os.Open(w.segmentPath(idx))
+	if err != nil {
+		return 0, 0, false, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return 0, 0, false, err
+	}
+	if _, ok := w.segMaxSeq[idx]; !ok {
+		w.segMaxSeq[idx] = 0
+	}
+
+	r := bufio.NewReader(f)
+	n := 0
+	var offset int64
+	for {
+		recs, size, err := decodeRecord(r, info.Size()-offset)
+		if err == io.EOF {
+			return n, offset, false, nil
+		}
+		if errors.Is(err, ErrCorruptedRecord) {
+			if size == 0 || offset+size == info.Size() {
+				// ostecena duzina takodje izgleda kao zapis koji prelazi kraj
+				after, rerr := recordAfter(f, offset, info.Size())
+				if rerr != nil {
+					return n, offset, false, fmt.Errorf("segment %d: %w", idx, rerr)
+				}
+				if !after {
+					return n, offset, true, nil
+				}
+			}
+			return n, offset, false, fmt.Errorf("segment %d at offset %d: %w", idx, offset, err)
+		}
+		if err != nil {
+			return n, offset, false, fmt.Errorf("segment %d: %w", idx, err)
+		}
+		offset += size
+		for _, rec := range recs {
+			if rec.Seq > w.segMaxSeq[idx] {
+				w.segMaxSeq[idx] = rec.Seq
+			}
+			if rec.Seq <= w.checkpoint {
+				continue
+			}
+			if err := fn(rec); err != nil {
+				return n, offset, false, err
+			}
+			n++
+		}
+	}
+}
+
+// recordAfter javlja da li negde iza offset-a u segmentu pocinje ispravan
+// zapis. Poluupisan rep je samo prefiks jednog zapisa, pa iza njega ne moze
+// biti zapisa sa ispravnim CRC-om.
+func recordAfter(f *os.File, offset, size int64) (bool, error) {
+	if size-offset-1 < headerSize {
+		return false, nil
+	}
+	tail := make([]byte, size-offset-1)
+	if _, err := f.ReadAt(tail, offset+1); err != nil {
+		return false, err
+	}
+	for i := 0; len(tail)-i >= headerSize; i++ {
+		if _, _, err := decodeRecord(bytes.NewReader(tail[i:]), int64(len(tail)-i)); err == nil {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// rollover zatvara trenutni segment i otvara sledeci. Zatvoreni segment se
+// uvek fsync-uje (osim u SyncNone), pa synced moze da se odnosi samo na w.file.
+func (w *WAL) rollover() error {
+	if w.file != nil {
+		if w.syncMode != SyncNone {
+			if err := w.syncLocked(); err != nil {
+				return err
+			}
+		}
+		w.synced = w.appended
+		if err := w.file.Close(); err != nil {
+			return err
+		}
+		w.file = nil
+	}
+
+	w.segIndex++
+	f, err := os.OpenFile(w.segmentPath(w.segIndex), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	w.file = f
+	w.segRecords = 0
+	w.segBytes = 0
+	w.segMaxSeq[w.segIndex] = 0
+	return nil
+}
+
+func (w *WAL) segmentPath(idx uint64) string {
+	return filepath.Join(w.dir, fmt.Sprintf("%s%08d%s", segmentPrefix, idx, segmentSuffix))
+}
+
+// listSegments vraca indekse postojecih segmenata, sortirane rastuce.
+func listSegments(dir string) ([]uint64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []uint64
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, segmentPrefix) || !strings.HasSuffix(name, segmentSuffix) {
+			continue
+		}
+		num := strings.TrimSuffix(strings.TrimPrefix(name, segmentPrefix), segmentSuffix)
+		idx, err := strconv.ParseUint(num, 10, 64)
+		if err != nil {
+			continue
+		}
+		out = append(out, idx)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	return out, nil
+}
+
+// Checkpoint fajl: [seq u64][crc u32]. Upisuje se atomicno, tako da je na
+// disku uvek ili stara ili nova vrednost.
+func writeCheckpoint(dir string, seq uint64) error {
+	buf := make([]byte, 12)
+	binary.BigEndian.PutUint64(buf[0:8], seq)
+	binary.BigEndian.PutUint32(buf[8:12], crc32.ChecksumIEEE(buf[0:8]))
+	return fileutil.WriteFileAtomic(filepath.Join(dir, checkpointFile), buf)
+}
+
+// readCheckpoint vraca 0 ako checkpoint ne postoji ili je ostecen,
+// sto samo znaci da ce replay proci kroz sve segmente.
+func readCheckpoint(path string) uint64 {
+	buf, err := os.ReadFile(path)
+	if err != nil || len(buf) != 12 {
+		return 0
+	}
+	if crc32.ChecksumIEEE(buf[0:8]) != binary.BigEndian.Uint32(buf[8:12]) {
+		return 0
+	}
+	return binary.BigEndian.Uint64(buf[0:8])
+}
+
+func encodeRecord(r model.Record) []byte {
+	key := []byte(r.Key)
+	val := r.Value
+	kind := kindPut
+	if r.Tombstone {
+		kind = kindDelete
+		val = nil
+	}
+
+	buf := make([]byte, headerSize+len(key)+len(val))
+	buf[4] = kind
+	binary.BigEndian.PutUint64(buf[5:13], r.Seq)
+	binary.BigEndian.PutUint64(buf[13:21], r.ExpiresAt)
+	binary.BigEndian.PutUint32(buf[21:25], uint32(len(key)))
+	binary.BigEndian.PutUint32(buf[25:29], uint32(len(val)))
+	copy(buf[headerSize:], key)
+	copy(buf[headerSize+len(key):], val)
+
+	binary.BigEndian.PutUint32(buf[0:4], crc32.ChecksumIEEE(buf[4:]))
+	return buf
+}
+
+// encodeBatch pakuje zapise sa uzastopnim seq-ovima u jedan batch zapis.
+func encodeBatch(recs []model.Record) []byte {
+	ops := binary.BigEndian.AppendUint32(nil, uint32(len(recs)))
+	for _, r := range recs {
+		kind, val := kindPut, r.Value
+		if r.Tombstone {
+			kind, val = kindDelete, nil
+		}
+		ops = append(ops, kind)
+		ops = binary.BigEndian.AppendUint64(ops, r.ExpiresAt)
+		ops = binary.BigEndian.AppendUint32(ops, uint32(len(r.Key)))
+		ops = binary.BigEndian.AppendUint32(ops, uint32(len(val)))
+		ops = append(ops, r.Key...)
+		ops = append(ops, val...)
+	}
+
+	buf := make([]byte, headerSize+len(ops))
+	buf[4] = kindBatch
+	binary.BigEndian.PutUint64(buf[5:13], recs[0].Seq)
+	binary.BigEndian.PutUint32(buf[25:29], uint32(len(ops)))
+	copy(buf[headerSize:], ops)
+
+	binary.BigEndian.PutUint32(buf[0:4], crc32.ChecksumIEEE(buf[4:]))
+	return buf
+}
+
+// decodeRecord vraca zapise jednog zapisa u segmentu (vise njih za batch) i
+// njegovu velicinu. Vraca io.EOF samo kada je reader tacno na kraju segmenta.
+// remaining je broj bajtova do kraja fajla i sluzi da ostecene duzine
+// ne izazovu ogromnu alokaciju. Uz ErrCorruptedRecord velicina je 0 kada
+// zapis prelazi kraj fajla, a inace je velicina zapisa prema header-u.
+func decodeRecord(r io.Reader, remaining int64) ([]model.Record, int64, error) {
+	header := make([]byte, headerSize)
+	n, err := io.ReadFull(r, header)
+	if err == io.EOF {
+		return nil, 0, io.EOF
+	}
+	if err == io.ErrUnexpectedEOF {
+		return nil, 0, fmt.Errorf("%w: short header (%d bytes)", ErrCorruptedRecord, n)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	keyLen := int64(binary.BigEndian.Uint32(header[21:25]))
+	valLen := int64(binary.BigEndian.Uint32(header[25:29]))
+	size := headerSize + keyLen + valLen
+	if size > remaining {
+		return nil, 0, fmt.Errorf("%w: record exceeds segment size", ErrCorruptedRecord)
+	}
+	kind := header[4]
+	if kind != kindPut && kind != kindDelete && kind != kindBatch {
+		return nil, size, fmt.Errorf("%w: unknown kind %d", ErrCorruptedRecord, kind)
+	}
+
+	payload := make([]byte, keyLen+valLen)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, 0, fmt.Errorf("%w: short payload", ErrCorruptedRecord)
+		}
+		return nil, 0, err
+	}
+
+	crc := crc32.NewIEEE()
+	crc.Write(header[4:])
+	crc.Write(payload)
+	if crc.Sum32() != binary.BigEndian.Uint32(header[0:4]) {
+		return nil, size, fmt.Errorf("%w: crc mismatch", ErrCorruptedRecord)
+	}
+
+	seq := binary.BigEndian.Uint64(header[5:13])
+	if kind == kindBatch {
+		recs, err := decodeBatch(payload, seq)
+		return recs, size, err
+	}
+
+	rec := model.Record{
+		Key:       string(payload[:keyLen]),
+		Tombstone: kind == kindDelete,
+		Seq:       seq,
+		ExpiresAt: binary.BigEndian.Uint64(header[13:21]),
+	}
+	if !rec.Tombstone {
+		rec.Value = payload[keyLen:]
+	}
+	return []model.Record{rec}, size, nil
+}
+
+// decodeBatch raspakuje operacije batch-a; CRC je vec proveren, pa losa
+// duzina znaci neispravan upis.
+func decodeBatch(ops []byte, seq uint64) ([]model.Record, error) {
+	bad := fmt.Errorf("%w: malformed batch", ErrCorruptedRecord)
+	if len(ops) < 4 {
+		return nil, bad
+	}
+	count := binary.BigEndian.Uint32(ops)
+	ops = ops[4:]
+
+	recs := make([]model.Record, 0, min(int(count), len(ops)/batchOpHeaderSize))
+	for i := uint32(0); i < count; i++ {
+		if len(ops) < batchOpHeaderSize {
+			return nil, bad
+		}
+		kind := ops[0]
+		keyLen := int(binary.BigEndian.Uint32(ops[9:13]))
+		valLen := int(binary.BigEndian.Uint32(ops[13:17]))
+		if (kind != kindPut && kind != kindDelete) || len(ops)-batchOpHeaderSize < keyLen+valLen {
+			return nil, bad
+		}
+
+		body := ops[batchOpHeaderSize:]
+		rec := model.Record{
+			Key:       string(body[:keyLen]),
+			Tombstone: kind == kindDelete,
+			Seq:       seq + uint64(i),
+			ExpiresAt: binary.BigEndian.Uint64(ops[1:9]),
+		}
+		if !rec.Tombstone {
+			rec.Value = body[keyLen : keyLen+valLen]
+		}
+		recs = append(recs, rec)
+		ops = body[keyLen+valLen:]
+	}
+	if len(ops) != 0 {
+		return nil, bad
+	}
+	return recs, nil
+}
@@ -0,0 +1,264 @@
+package wal
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+
+	"kv-engine/internal/model"
+)
+
+func openTestWAL(t *testing.T, dir string, segmentMaxRecords int) *WAL {
+	t.Helper()
+	w, err := New(dir, segmentMaxRecords, SyncAlways, 0)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return w
+}
+
+func replayAll(w *WAL) ([]model.Record, error) {
+	var out []model.Record
+	_, err := w.Replay(func(r model.Record) error {
+		out = append(out, r)
+		return nil
+	})
+	return out, err
+}
+
+func puts(from, n int) []model.Record {
+	var recs []model.Record
+	for i := from; i < from+n; i++ {
+		recs = append(recs, model.Record{Key: fmt.Sprintf("k%d", i), Value: []byte("v"), Seq: uint64(i)})
+	}
+	return recs
+}
+
+func TestRecordRoundTrip(t *testing.T) {
+	recs := []model.Record{
+		{Key: "a", Value: []byte("1"), Seq: 1},
+		{Key: "b", Tombstone: true, Seq: 2},
+		{Key: "c", Value: []byte("3"), Seq: 3, ExpiresAt: 1700000000},
+		{Key: "", Value: []byte{}, Seq: 4},
+	}
+	for _, want := range recs {
+		buf := encodeRecord(want)
+		got, size, err := decodeRecord(bytes.NewReader(buf), int64(len(buf)))
+		if err != nil || size != int64(len(buf)) {
+			t.Fatalf("decode %q: size=%d err=%v", want.Key, size, err)
+		}
+		if len(got) != 1 || got[0].Key != want.Key || got[0].Seq != want.Seq ||
+			got[0].Tombstone != want.Tombstone || got[0].ExpiresAt != want.ExpiresAt ||
+			!bytes.Equal(got[0].Value, want.Value) {
+			t.Fatalf("decode = %+v, want %+v", got, want)
+		}
+	}
+
+	batch := []model.Record{
+		{Key: "x", Value: []byte("1"), Seq: 10},
+		{Key: "y", Tombstone: true, Seq: 11},
+		{Key: "z", Value: []byte("3"), Seq: 12, ExpiresAt: 5},
+	}
+	buf := encodeBatch(batch)
+	got, _, err := decodeRecord(bytes.NewReader(buf), int64(len(buf)))
+	if err != nil {
+		t.Fatalf("decode batch: %v", err)
+	}
+	if !reflect.DeepEqual(got, batch) {
+		t.Fatalf("batch = %+v, want %+v", got, batch)
+	}
+}
+
+func TestDecodeRejectsCorruption(t *testing.T) {
+	buf := encodeRecord(model.Record{Key: "key", Value: []byte("value"), Seq: 7})
+
+	flipped := append([]byte(nil), buf...)
+	flipped[len(flipped)-1] ^= 0x01
+	if _, size, err := decodeRecord(bytes.NewReader(flipped), int64(len(flipped))); !errors.Is(err, ErrCorruptedRecord) || size != int64(len(buf)) {
+		t.Fatalf("crc: size=%d err=%v", size, err)
+	}
+
+	// duzina kljuca prelazi kraj fajla: nema alokacije, velicina nepoznata
+	huge := append([]byte(nil), buf...)
+	huge[21] = 0xff
+	if _, size, err := decodeRecord(bytes.NewReader(huge), int64(len(huge))); !errors.Is(err, ErrCorruptedRecord) || size != 0 {
+		t.Fatalf("length: size=%d err=%v", size, err)
+	}
+
+	if _, _, err := decodeRecord(bytes.NewReader(buf[:10]), 10); !errors.Is(err, ErrCorruptedRecord) {
+		t.Fatalf("short header: %v", err)
+	}
+}
+
+func TestReplayTornTail(t *testing.T) {
+	dir := t.TempDir()
+	w := openTestWAL(t, dir, 100)
+	if err := w.Append(puts(1, 5)...); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+
+	// poluupisan zapis na kraju poslednjeg segmenta
+	path := w.segmentPath(1)
+	f, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	rec := encodeRecord(model.Record{Key: "torn", Value: []byte("v"), Seq: 6})
+	f.Write(rec[:len(rec)-2])
+	f.Close()
+
+	w = openTestWAL(t, dir, 100)
+	got, err := replayAll(w)
+	if err != nil || len(got) != 5 {
+		t.Fatalf("replay: %d records, err=%v", len(got), err)
+	}
+	// upis posle oporavka mora da prezivi sledeci start
+	if err := w.Append(puts(6, 1)...); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+
+	w = openTestWAL(t, dir, 100)
+	defer w.Close()
+	got, err = replayAll(w)
+	if err != nil || len(got) != 6 || got[5].Key != "k6" {
+		t.Fatalf("second replay: %+v, err=%v", got, err)
+	}
+}
+
+func TestReplayCorruptLastRecordIsTorn(t *testing.T) {
+	dir := t.TempDir()
+	w := openTestWAL(t, dir, 100)
+	w.Append(puts(1, 3)...)
+	w.Close()
+
+	path := w.segmentPath(1)
+	data, _ := os.ReadFile(path)
+	data[len(data)-1] ^= 0x01
+	os.WriteFile(path, data, 0644)
+
+	w = openTestWAL(t, dir, 100)
+	defer w.Close()
+	got, err := replayAll(w)
+	if err != nil || len(got) != 2 {
+		t.Fatalf("replay: %d records, err=%v", len(got), err)
+	}
+}
+
+func TestReplayCorruptMiddleOfSegment(t *testing.T) {
+	dir := t.TempDir()
+	w := openTestWAL(t, dir, 100)
+	w.Append(puts(1, 3)...)
+	w.Close()
+
+	path := w.segmentPath(1)
+	data, _ := os.ReadFile(path)
+	data[headerSize] ^= 0x01 // kljuc prvog zapisa
+	os.WriteFile(path, data, 0644)
+
+	w = openTestWAL(t, dir, 100)
+	defer w.Close()
+	if _, err := replayAll(w); !errors.Is(err, ErrCorruptedRecord) {
+		t.Fatalf("replay: %v, want ErrCorruptedRecord", err)
+	}
+	if after, _ := os.ReadFile(path); len(after) != len(data) {
+		t.Fatalf("segment truncated to %d bytes", len(after))
+	}
+}
+
+func TestReplayCorruptLengthInLastSegment(t *testing.T) {
+	dir := t.TempDir()
+	w := openTestWAL(t, dir, 100)
+	w.Append(puts(1, 5)...)
+	w.Close()
+
+	// keyLen drugog zapisa pokazuje iza kraja fajla
+	path := w.segmentPath(1)
+	data, _ := os.ReadFile(path)
+	recSize := len(encodeRecord(puts(1, 1)[0]))
+	data[recSize+21] ^= 0x01
+	os.WriteFile(path, data, 0644)
+
+	w = openTestWAL(t, dir, 100)
+	defer w.Close()
+	if got, err := replayAll(w); !errors.Is(err, ErrCorruptedRecord) {
+		t.Fatalf("replay: %d records, err=%v, want ErrCorruptedRecord", len(got), err)
+	}
+	if after, _ := os.ReadFile(path); len(after) != len(data) {
+		t.Fatalf("segment truncated to %d bytes", len(after))
+	}
+}
+
+func TestReplayCorruptEarlierSegment(t *testing.T) {
+	dir := t.TempDir()
+	w := openTestWAL(t, dir, 2)
+	for _, r := range puts(1, 6) {
+		w.Append(r)
+	}
+	w.Close()
+
+	// poslednji zapis prvog segmenta; iza njega su jos dva segmenta
+	path := w.segmentPath(1)
+	data, _ := os.ReadFile(path)
+	data[len(data)-1] ^= 0x01
+	os.WriteFile(path, data, 0644)
+
+	w = openTestWAL(t, dir, 2)
+	defer w.Close()
+	if _, err := replayAll(w); !errors.Is(err, ErrCorruptedRecord) {
+		t.Fatalf("replay: %v, want ErrCorruptedRecord", err)
+	}
+}
+
+func TestFailedWriteLeavesNoPartialRecords(t *testing.T) {
+	dir := t.TempDir()
+	w := openTestWAL(t, dir, 2)
+	if err := w.Append(puts(1, 1)...); err != nil {
+		t.Fatal(err)
+	}
+	info, _ := os.Stat(w.segmentPath(1))
+
+	// sledeci segment ne moze da se otvori, pa drugi zapis ne uspeva posle
+	// prvog koji je vec upisan u segment 1
+	os.Mkdir(w.segmentPath(2), 0755)
+	if err := w.AppendGroups(puts(2, 1), puts(3, 1)); err == nil {
+		t.Fatal("AppendGroups succeeded")
+	}
+	if after, _ := os.Stat(w.segmentPath(1)); after.Size() != info.Size() {
+		t.Fatalf("segment 1 has %d bytes, want %d", after.Size(), info.Size())
+	}
+	if err := w.Append(puts(4, 1)...); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+
+	w = openTestWAL(t, dir, 2)
+	defer w.Close()
+	got, err := replayAll(w)
+	if err != nil || len(got) != 2 || got[0].Seq != 1 || got[1].Seq != 4 {
+		t.Fatalf("replay = %+v, err=%v, want seq 1 and 4", got, err)
+	}
+}
+
+func TestReplaySkipsCheckpointed(t *testing.T) {
+	dir := t.TempDir()
+	w := openTestWAL(t, dir, 2)
+	for _, r := range puts(1, 5) {
+		w.Append(r)
+	}
+	if err := w.Checkpoint(3); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+
+	w = openTestWAL(t, dir, 2)
+	defer w.Close()
+	got, err := replayAll(w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].Seq != 4 || got[1].Seq != 5 {
+		t.Fatalf("replay = %+v, want seq 4 and 5", got)
+	}
+}